@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	_ "github.com/PRPO-skupina-02/auth/api/docs"
 	"github.com/PRPO-skupina-02/auth/auth"
@@ -27,6 +28,9 @@ import (
 //	@description				Type "Bearer" followed by a space and JWT token.
 
 func Register(router *gin.Engine, db *gorm.DB, trans ut.Translator) {
+	auth.SetKeyring(auth.NewKeyring(models.NewKeyStore(db)))
+	registerPasswordTranslations(trans)
+
 	// Healthcheck
 	router.GET("/healthcheck", healthcheck)
 
@@ -39,19 +43,55 @@ func Register(router *gin.Engine, db *gorm.DB, trans ut.Translator) {
 	v1.Use(middleware.TranslationMiddleware(trans))
 	v1.Use(middleware.ErrorMiddleware)
 
+	// Discovery
+	v1.GET("/.well-known/jwks.json", JWKS)
+	v1.GET("/.well-known/auth-configuration", AuthConfiguration)
+
 	// Public routes
-	v1.POST("/register", RegisterUser)
-	v1.POST("/login", Login)
-	v1.POST("/refresh", RefreshToken)
-	v1.POST("/verify", VerifyToken)
+	limiter := newRateLimitStore(db)
+
+	v1.POST("/register", RateLimit(limiter, registerLimits), RegisterUser)
+	v1.POST("/login", RateLimit(limiter, loginLimits), Login)
+	v1.POST("/login/mfa", RateLimit(limiter, tokenLimits), LoginMFA)
+	v1.POST("/login/mfa/passkey/options", RateLimit(limiter, tokenLimits), LoginMFAPasskeyOptions)
+	v1.POST("/login/mfa/totp", RateLimit(limiter, tokenLimits), LoginTOTPEnroll)
+	v1.POST("/login/mfa/totp/confirm", RateLimit(limiter, tokenLimits), LoginTOTPConfirm)
+	v1.POST("/login/passkey/options", RateLimit(limiter, tokenLimits), LoginPasskeyOptions)
+	v1.POST("/login/passkey", RateLimit(limiter, tokenLimits), LoginPasskey)
+	v1.POST("/login/email-code", RateLimit(limiter, emailLinkLimits), RequestEmailLogin)
+	v1.POST("/login/email-code/verify", RateLimit(limiter, loginLimits), LoginEmailCode)
+	v1.POST("/refresh", RateLimit(limiter, refreshLimits), RefreshToken)
+	v1.POST("/verify", RateLimit(limiter, verifyLimits), VerifyToken)
+	v1.POST("/introspect", RateLimit(limiter, introspectLimits), Introspect)
+	v1.POST("/verify-email", RateLimit(limiter, tokenLimits), VerifyEmail)
+	v1.POST("/verify-email/resend", RateLimit(limiter, emailLinkLimits), ResendVerificationEmail)
+	v1.POST("/password/forgot", RateLimit(limiter, emailLinkLimits), ForgotPassword)
+	v1.POST("/password/reset", RateLimit(limiter, tokenLimits), ResetPassword)
+	v1.POST("/email/confirm", RateLimit(limiter, tokenLimits), ConfirmEmailChange)
 
 	// Protected routes
 	protected := v1.Group("")
 	protected.Use(AuthMiddleware())
 
+	protected.POST("/logout", Logout)
+	protected.POST("/logout-all", LogoutAll)
+	protected.POST("/reauthenticate", RateLimit(limiter, tokenLimits), Reauthenticate)
+	protected.POST("/reauthenticate/passkey/options", RateLimit(limiter, tokenLimits), ReauthenticatePasskeyOptions)
+	protected.GET("/userinfo", UserInfo)
+	protected.POST("/userinfo", UserInfo)
 	protected.GET("/me", GetCurrentUser)
 	protected.PUT("/me", UpdateCurrentUser)
-	protected.PUT("/me/password", ChangePassword)
+	protected.PUT("/me/password", RateLimit(limiter, loginLimits), RequireRecentAuth(auth.GetRecentAuthMaxAge()), ChangePassword)
+	protected.PUT("/me/email", RateLimit(limiter, emailLinkLimits), ChangeEmail)
+	protected.GET("/me/mfa", MFAStatus)
+	protected.DELETE("/me/mfa", RateLimit(limiter, tokenLimits), MFADisable)
+	protected.POST("/me/mfa/totp", RateLimit(limiter, tokenLimits), TOTPEnroll)
+	protected.POST("/me/mfa/totp/confirm", TOTPConfirm)
+	protected.POST("/me/mfa/recovery-codes", RateLimit(limiter, tokenLimits), MFARegenerateRecoveryCodes)
+	protected.GET("/me/passkeys", PasskeysList)
+	protected.POST("/me/passkeys/options", RateLimit(limiter, tokenLimits), PasskeyRegisterOptions)
+	protected.POST("/me/passkeys", PasskeyRegister)
+	protected.DELETE("/me/passkeys/:passkeyID", PasskeyDelete)
 
 	// Admin routes (for managing users)
 	admin := v1.Group("/users")
@@ -62,7 +102,16 @@ func Register(router *gin.Engine, db *gorm.DB, trans ut.Translator) {
 	admin.GET("/:userID", UsersShow)
 	admin.POST("", AdminCreateUser)
 	admin.PUT("/:userID", UsersUpdate)
-	admin.DELETE("/:userID", UsersDelete)
+	admin.DELETE("/:userID", RequireRecentAuth(auth.GetRecentAuthMaxAge()), UsersDelete)
+	admin.POST("/:userID/unlock", UsersUnlock)
+
+	// Admin routes (for managing signing keys)
+	keys := v1.Group("/keys")
+	keys.Use(AuthMiddleware())
+	keys.Use(RequireAdmin())
+
+	keys.GET("", KeysList)
+	keys.POST("/rotate", KeysRotate)
 }
 
 func healthcheck(c *gin.Context) {
@@ -86,7 +135,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := authHeader[len(bearerPrefix):]
-		claims, err := auth.ValidateToken(tokenString)
+		claims, err := auth.ValidateToken(tokenString, auth.TokenTypeAccess)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			return
@@ -105,11 +154,34 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if isAccessTokenRevoked(claims, user) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+
+		if !satisfiesMFAPolicy(claims, user) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": errMFARequired})
+			return
+		}
+
 		// Set user info in context
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_role", user.Role)
+		c.Set("token_claims", claims)
 
 		c.Next()
 	}
 }
+
+// isAccessTokenRevoked checks whether the token was revoked together with every
+// other token of the user, e.g. by a logout from all sessions or a deactivation.
+// Revocations of single tokens are already handled by auth.ValidateToken.
+func isAccessTokenRevoked(claims *auth.Claims, user models.User) bool {
+	issuedAt := time.Time{}
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	return user.TokensRevokedSince(issuedAt)
+}
@@ -2,18 +2,52 @@ package api
 
 import (
 	"testing"
+	"time"
 
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
 	"github.com/PRPO-skupina-02/common/validation"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 )
 
+// TestingMFALogin marks a token as issued after a login with TOTP, which admins
+// and employees need
+var TestingMFALogin = auth.WithAMR(auth.AMRPassword, auth.AMROTP, auth.AMRMultiFactor)
+
+// TestingRecentAuth marks a token as issued right after a login, which sensitive
+// operations need
+func TestingRecentAuth() auth.TokenOption {
+	return auth.WithAuthTime(time.Now())
+}
+
 func TestingRouter(t *testing.T, db *gorm.DB) *gin.Engine {
 	router := gin.Default()
+	err := router.SetTrustedProxies(GetTrustedProxies())
+	require.NoError(t, err)
 	trans, err := validation.RegisterValidation()
 	require.NoError(t, err)
 	Register(router, db, trans)
+	auth.SetRevocationList(auth.NewRevocationList(models.NewRevocationBackend(db)))
 
 	return router
 }
+
+// TestingRefreshToken issues a refresh token and persists it as the start of a new family
+func TestingRefreshToken(t *testing.T, db *gorm.DB, userID uuid.UUID, email string, opts ...auth.TokenOption) (string, models.RefreshToken) {
+	token, err := auth.GenerateRefreshToken(userID, email, opts...)
+	require.NoError(t, err)
+
+	storedToken := models.RefreshToken{
+		UserID:    userID,
+		TokenHash: auth.HashToken(token),
+		FamilyID:  uuid.New(),
+		ExpiresAt: time.Now().Add(auth.GetRefreshTokenTTL()),
+	}
+	err = storedToken.Create(db)
+	require.NoError(t, err)
+
+	return token, storedToken
+}
@@ -1,18 +1,22 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/PRPO-skupina-02/auth/auth"
 	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/auth/models"
 	"github.com/PRPO-skupina-02/common/database"
 	"github.com/PRPO-skupina-02/common/xtesting"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRegister(t *testing.T) {
@@ -28,7 +32,7 @@ func TestRegister(t *testing.T) {
 			name: "ok",
 			body: RegisterRequest{
 				Email:     "newcustomer@example.com",
-				Password:  "password123",
+				Password:  "newpassword123",
 				FirstName: "New",
 				LastName:  "Customer",
 			},
@@ -38,7 +42,7 @@ func TestRegister(t *testing.T) {
 			name: "duplicate-email",
 			body: RegisterRequest{
 				Email:     "customer@example.com",
-				Password:  "password123",
+				Password:  "newpassword123",
 				FirstName: "Duplicate",
 				LastName:  "User",
 			},
@@ -48,7 +52,7 @@ func TestRegister(t *testing.T) {
 			name: "validation-error-email",
 			body: RegisterRequest{
 				Email:     "invalid-email",
-				Password:  "password123",
+				Password:  "newpassword123",
 				FirstName: "Test",
 				LastName:  "User",
 			},
@@ -64,6 +68,26 @@ func TestRegister(t *testing.T) {
 			},
 			status: http.StatusBadRequest,
 		},
+		{
+			name: "validation-error-password-too-weak",
+			body: RegisterRequest{
+				Email:     "test@example.com",
+				Password:  "password123",
+				FirstName: "Test",
+				LastName:  "User",
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "validation-error-password-contains-email",
+			body: RegisterRequest{
+				Email:     "zebralamp@example.com",
+				Password:  "zebralamp-2024",
+				FirstName: "Test",
+				LastName:  "User",
+			},
+			status: http.StatusBadRequest,
+		},
 		{
 			name:   "no-body",
 			status: http.StatusBadRequest,
@@ -98,6 +122,116 @@ func TestRegister(t *testing.T) {
 	}
 }
 
+func TestRegisterGeneric(t *testing.T) {
+	t.Setenv("REGISTRATION_RESPONSE", auth.RegistrationResponseGeneric)
+
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	tests := []struct {
+		name   string
+		body   any
+		status int
+	}{
+		{
+			name: "ok",
+			body: RegisterRequest{
+				Email:     "newuser@example.com",
+				Password:  "newpassword123",
+				FirstName: "New",
+				LastName:  "User",
+			},
+			status: http.StatusAccepted,
+		},
+		{
+			name: "existing-email",
+			body: RegisterRequest{
+				Email:     "customer@example.com",
+				Password:  "newpassword123",
+				FirstName: "Duplicate",
+				LastName:  "User",
+			},
+			status: http.StatusAccepted,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			targetURL := "/api/v1/auth/register"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+		})
+	}
+
+	// The existing account is left alone
+	user, err := models.GetUserByEmail(db, "customer@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Customer", user.FirstName)
+}
+
+func TestLoginInactiveUser(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	user, err := models.GetUserByEmail(db, "customer@example.com")
+	require.NoError(t, err)
+	user.Active = false
+	err = user.Save(db)
+	require.NoError(t, err)
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: "customer123",
+	})
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	// Same as a wrong password, so the response doesn't reveal the account exists
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	t.Setenv("PASSWORD_HASH_ALGORITHM", models.PasswordHashArgon2id)
+	t.Setenv("ARGON2_MEMORY", "64")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+
+	for range 2 {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+			Email:    "customer@example.com",
+			Password: "customer123",
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// The fixture's bcrypt hash is replaced on the first login
+	user, err := models.GetUserByEmail(db, "customer@example.com")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(user.PasswordHash, "$argon2id$v=19$m=64,t=1,p=1$"))
+}
+
 func TestLogin(t *testing.T) {
 	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
 	r := TestingRouter(t, db)
@@ -168,6 +302,8 @@ func TestLogin(t *testing.T) {
 			ignoreResp := xtesting.ValuesCheckers{
 				"access_token":  xtesting.ValueNotEqual(""),
 				"refresh_token": xtesting.ValueNotEqual(""),
+				"id_token":      xtesting.ValueNotEqual(""),
+				"mfa_token":     xtesting.ValueNotEqual(""),
 			}
 
 			assert.Equal(t, testCase.status, w.Code)
@@ -187,6 +323,7 @@ func TestVerifyToken(t *testing.T) {
 	// Generate valid tokens for testing
 	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
 	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	refreshToken, _ := auth.GenerateRefreshToken(customerID, "customer@example.com")
 
 	tests := []struct {
 		name   string
@@ -200,6 +337,13 @@ func TestVerifyToken(t *testing.T) {
 			},
 			status: http.StatusOK,
 		},
+		{
+			name: "refresh-token",
+			body: map[string]string{
+				"token": refreshToken,
+			},
+			status: http.StatusUnauthorized,
+		},
 		{
 			name: "invalid-token",
 			body: map[string]string{
@@ -235,9 +379,17 @@ func TestRefreshToken(t *testing.T) {
 	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
 	r := TestingRouter(t, db)
 
-	// Generate valid refresh token for testing
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	// Generate valid refresh tokens for testing
 	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
-	validRefreshToken, _ := auth.GenerateRefreshToken(customerID, "customer@example.com")
+	validRefreshToken, _ := TestingRefreshToken(t, db, customerID, "customer@example.com")
+	rotatedRefreshToken, rotatedStoredToken := TestingRefreshToken(t, db, customerID, "customer@example.com")
+	err = rotatedStoredToken.Revoke(db)
+	require.NoError(t, err)
+	unknownRefreshToken, _ := auth.GenerateRefreshToken(customerID, "customer@example.com")
+	accessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
 
 	tests := []struct {
 		name   string
@@ -251,6 +403,27 @@ func TestRefreshToken(t *testing.T) {
 			},
 			status: http.StatusOK,
 		},
+		{
+			name: "rotated-token",
+			body: map[string]string{
+				"refresh_token": rotatedRefreshToken,
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown-token",
+			body: map[string]string{
+				"refresh_token": unknownRefreshToken,
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "access-token",
+			body: map[string]string{
+				"refresh_token": accessToken,
+			},
+			status: http.StatusUnauthorized,
+		},
 		{
 			name: "invalid-token",
 			body: map[string]string{
@@ -279,6 +452,7 @@ func TestRefreshToken(t *testing.T) {
 			ignoreResp := xtesting.ValuesCheckers{
 				"access_token":  xtesting.ValueNotEqual(""),
 				"refresh_token": xtesting.ValueNotEqual(""),
+				"id_token":      xtesting.ValueNotEqual(""),
 			}
 
 			assert.Equal(t, testCase.status, w.Code)
@@ -291,12 +465,199 @@ func TestRefreshToken(t *testing.T) {
 	}
 }
 
+func TestRefreshTokenReuse(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	refresh := func(refreshToken string) (int, TokenResponse) {
+		body := map[string]string{"refresh_token": refreshToken}
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/refresh", http.MethodPost, body)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var tokens TokenResponse
+		if w.Code == http.StatusOK {
+			err := json.Unmarshal(w.Body.Bytes(), &tokens)
+			require.NoError(t, err)
+		}
+		return w.Code, tokens
+	}
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	originalToken, storedToken := TestingRefreshToken(t, db, customerID, "customer@example.com")
+
+	// Rotating works once
+	status, rotated := refresh(originalToken)
+	assert.Equal(t, http.StatusOK, status)
+
+	// Replaying the original token is rejected and revokes the family
+	status, _ = refresh(originalToken)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// The token issued by the legitimate rotation is no longer usable either
+	status, _ = refresh(rotated.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	var active int64
+	err = db.Model(&models.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", storedToken.FamilyID).Count(&active).Error
+	require.NoError(t, err)
+	assert.Zero(t, active)
+}
+
+func TestLogout(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	accessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	refreshToken, _ := TestingRefreshToken(t, db, customerID, "customer@example.com")
+	otherAccessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name         string
+		token        string
+		body         LogoutRequest
+		status       int
+		refreshToken string
+	}{
+		{
+			name:         "ok",
+			token:        accessToken,
+			body:         LogoutRequest{RefreshToken: refreshToken},
+			status:       http.StatusOK,
+			refreshToken: refreshToken,
+		},
+		{
+			name:   "ok-without-refresh-token",
+			token:  otherAccessToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "no-token",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			targetURL := "/api/v1/auth/logout"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+
+			if testCase.status != http.StatusOK {
+				return
+			}
+
+			// The access token can no longer be used
+			req = xtesting.NewTestingRequest(t, "/api/v1/auth/me", http.MethodGet, nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+			// Neither can the refresh token of the session
+			if testCase.refreshToken != "" {
+				body := map[string]string{"refresh_token": testCase.refreshToken}
+				req = xtesting.NewTestingRequest(t, "/api/v1/auth/refresh", http.MethodPost, body)
+				w = httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+			}
+		})
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	accessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	otherAccessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	refreshToken, _ := TestingRefreshToken(t, db, customerID, "customer@example.com")
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{
+			name:   "ok",
+			token:  accessToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "no-token",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			targetURL := "/api/v1/auth/logout-all"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, nil)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+
+			if testCase.status != http.StatusOK {
+				return
+			}
+
+			// Tokens of other sessions are revoked as well
+			req = xtesting.NewTestingRequest(t, "/api/v1/auth/me", http.MethodGet, nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", otherAccessToken))
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+			body := map[string]string{"refresh_token": refreshToken}
+			req = xtesting.NewTestingRequest(t, "/api/v1/auth/refresh", http.MethodPost, body)
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+	}
+}
+
 func TestGetCurrentUser(t *testing.T) {
 	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
 	r := TestingRouter(t, db)
 
 	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
 	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	refreshToken, _ := auth.GenerateRefreshToken(customerID, "customer@example.com")
 
 	tests := []struct {
 		name   string
@@ -312,6 +673,11 @@ func TestGetCurrentUser(t *testing.T) {
 			name:   "no-token",
 			status: http.StatusUnauthorized,
 		},
+		{
+			name:   "refresh-token",
+			token:  refreshToken,
+			status: http.StatusUnauthorized,
+		},
 		{
 			name:   "invalid-token",
 			token:  "invalid.jwt.token",
@@ -410,7 +776,8 @@ func TestChangePassword(t *testing.T) {
 	r := TestingRouter(t, db)
 
 	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
-	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com", TestingRecentAuth())
+	staleToken, _ := auth.GenerateToken(customerID, "customer@example.com", auth.WithAuthTime(time.Now().Add(-time.Hour)))
 
 	tests := []struct {
 		name   string
@@ -445,6 +812,24 @@ func TestChangePassword(t *testing.T) {
 			},
 			status: http.StatusBadRequest,
 		},
+		{
+			name:  "new-password-contains-name",
+			token: validToken,
+			body: ChangePasswordRequest{
+				OldPassword: "customer123",
+				NewPassword: "customer-2024!",
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:  "not-recent-auth",
+			token: staleToken,
+			body: ChangePasswordRequest{
+				OldPassword: "customer123",
+				NewPassword: "newpassword123",
+			},
+			status: http.StatusUnauthorized,
+		},
 		{
 			name:   "no-token",
 			status: http.StatusUnauthorized,
@@ -471,3 +856,89 @@ func TestChangePassword(t *testing.T) {
 		})
 	}
 }
+
+func TestChangePasswordLockout(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1ms")
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	token, _ := auth.GenerateToken(customerID, "customer@example.com", TestingRecentAuth())
+
+	changePassword := func(oldPassword string) *httptest.ResponseRecorder {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/password", http.MethodPut, ChangePasswordRequest{
+			OldPassword: oldPassword,
+			NewPassword: "zebra-lamp-42",
+		})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// A stolen access token can't be used to guess the old password
+	for range 3 {
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, http.StatusUnauthorized, changePassword("wrongpassword").Code)
+	}
+
+	user, err := models.GetUser(db, customerID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, user.FailedLoginAttempts)
+	assert.True(t, user.IsLocked())
+
+	w := changePassword("customer123")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+
+	user, err = models.GetUser(db, customerID)
+	require.NoError(t, err)
+	assert.NoError(t, user.CheckPassword("customer123"))
+}
+
+func TestChangePasswordHistory(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	t.Setenv("PASSWORD_HISTORY_SIZE", "2")
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	token, _ := auth.GenerateToken(customerID, "customer@example.com", TestingRecentAuth())
+
+	changePassword := func(oldPassword string, newPassword string) *httptest.ResponseRecorder {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/password", http.MethodPut, ChangePasswordRequest{
+			OldPassword: oldPassword,
+			NewPassword: newPassword,
+		})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, changePassword("customer123", "zebra-lamp-42").Code)
+	require.Equal(t, http.StatusOK, changePassword("zebra-lamp-42", "violet-harbor-77").Code)
+
+	// The current and the previous password can't be set again
+	assert.Equal(t, http.StatusBadRequest, changePassword("violet-harbor-77", "violet-harbor-77").Code)
+	w := changePassword("violet-harbor-77", "zebra-lamp-42")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+
+	// Older passwords are forgotten
+	require.Equal(t, http.StatusOK, changePassword("violet-harbor-77", "amber-forest-19").Code)
+	assert.Equal(t, http.StatusOK, changePassword("amber-forest-19", "zebra-lamp-42").Code)
+
+	var count int64
+	err = db.Model(&models.PasswordHistory{}).Where("user_id = ?", customerID).Count(&count).Error
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"new_email" binding:"required,email"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+// sendEmailChangeEmails issues a new email change token, invalidating older ones.
+// The confirmation link goes to the new address, and the old address is told about
+// the request so a hijacked session can't quietly take over the account.
+func sendEmailChangeEmails(tx *gorm.DB, user models.User, newEmail string) error {
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	if err := models.InvalidateUserEmailChangeTokens(tx, user.ID); err != nil {
+		return err
+	}
+
+	changeToken := models.EmailChangeToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		OldEmail:  user.Email,
+		NewEmail:  newEmail,
+		ExpiresAt: time.Now().Add(auth.GetEmailVerificationTTL()),
+	}
+	if err := changeToken.Create(tx); err != nil {
+		return err
+	}
+
+	sendEmail(newEmail, "email-change", map[string]interface{}{
+		"Subject":     "Confirm your new email address",
+		"UserName":    user.FirstName,
+		"ConfirmLink": fmt.Sprintf("%s/confirm-email?token=%s", getFrontendURL(), url.QueryEscape(token)),
+	})
+
+	sendEmailChangeNotice(user, newEmail)
+
+	return nil
+}
+
+// sendEmailChangeNotice tells the current address of the user about a requested change
+func sendEmailChangeNotice(user models.User, newEmail string) {
+	sendEmail(user.Email, "email-change-requested", map[string]interface{}{
+		"Subject":  "Your email address is being changed",
+		"UserName": user.FirstName,
+		"NewEmail": newEmail,
+	})
+}
+
+// sendEmailTakenNotice is sent instead of the confirmation link when the new address
+// already has an account, telling its owner that someone tried to use it. The
+// requesting user gets the same notice as for a free address.
+func sendEmailTakenNotice(tx *gorm.DB, user models.User, newEmail string) error {
+	if err := models.InvalidateUserEmailChangeTokens(tx, user.ID); err != nil {
+		return err
+	}
+
+	sendEmail(newEmail, "email-change-address-taken", map[string]interface{}{
+		"Subject":   "Someone tried to use your email address",
+		"LoginLink": fmt.Sprintf("%s/login", getFrontendURL()),
+		"ResetLink": fmt.Sprintf("%s/forgot-password", getFrontendURL()),
+	})
+
+	sendEmailChangeNotice(user, newEmail)
+
+	return nil
+}
+
+// ChangeEmail
+//
+//	@Id				ChangeEmail
+//	@Summary		Change email address
+//	@Description	Request a change of the email address. A confirmation link is sent to the new address and a notification to the current one; the address only changes once the link is followed. The response is the same when the new address already has an account, whose owner is emailed instead, so it doesn't reveal which addresses are registered. Wrong passwords count as failed logins of the account.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		ChangeEmailRequest	true	"New email address and current password"
+//	@Success		202		{object}	object{message=string}
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		413		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/me/email [put]
+func ChangeEmail(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := models.GetUser(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if !checkUserPassword(c, tx, &user, req.CurrentPassword) {
+		return
+	}
+	if !resetFailedLogins(c, tx, &user) {
+		return
+	}
+
+	if req.NewEmail == user.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New email address is the same as the current one"})
+		return
+	}
+
+	exists, err := models.UserExists(tx, req.NewEmail)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if exists {
+		err = sendEmailTakenNotice(tx, user, req.NewEmail)
+	} else {
+		err = sendEmailChangeEmails(tx, user, req.NewEmail)
+	}
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Check the new email address to confirm the change"})
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ConfirmEmailChange
+//
+//	@Id				ConfirmEmailChange
+//	@Summary		Confirm email address change
+//	@Description	Switch to the new email address with the token from the confirmation email. The token can only be used once, and fails if the address was taken in the meantime.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		ConfirmEmailChangeRequest	true	"Confirmation token"
+//	@Success		200		{object}	object{message=string}
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		409		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/email/confirm [post]
+func ConfirmEmailChange(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	changeToken, err := models.GetEmailChangeTokenByHash(tx, auth.HashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+			return
+		}
+		_ = c.Error(err)
+		return
+	}
+
+	if !changeToken.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+		return
+	}
+
+	user, err := models.GetUser(tx, changeToken.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// The address may have been changed another way since the request, e.g. by an admin
+	if !user.Active || user.Email != changeToken.OldEmail {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+		return
+	}
+
+	exists, err := models.UserExists(tx, changeToken.NewEmail)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+		return
+	}
+
+	if err := changeToken.MarkUsed(tx); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user.Email = changeToken.NewEmail
+	if err := user.Save(tx); err != nil {
+		// Another account may have claimed the address since the check above
+		if models.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+			return
+		}
+		_ = c.Error(err)
+		return
+	}
+
+	// Following the emailed link proves the user owns the new address
+	if err := user.MarkEmailVerified(tx); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address changed successfully"})
+}
@@ -0,0 +1,279 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// testingEmailChangeToken issues an email change token for the customer expiring at the given time
+func testingEmailChangeToken(t *testing.T, db *gorm.DB, newEmail string, expiresAt time.Time) (string, models.EmailChangeToken) {
+	token, err := auth.GenerateOpaqueToken()
+	require.NoError(t, err)
+
+	changeToken := models.EmailChangeToken{
+		UserID:    uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+		TokenHash: auth.HashToken(token),
+		OldEmail:  "customer@example.com",
+		NewEmail:  newEmail,
+		ExpiresAt: expiresAt,
+	}
+	err = changeToken.Create(db)
+	require.NoError(t, err)
+
+	return token, changeToken
+}
+
+func TestChangeEmail(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name   string
+		token  string
+		body   ChangeEmailRequest
+		status int
+	}{
+		{
+			name:  "ok",
+			token: validToken,
+			body: ChangeEmailRequest{
+				NewEmail:        "newcustomer@example.com",
+				CurrentPassword: "customer123",
+			},
+			status: http.StatusAccepted,
+		},
+		{
+			name:  "wrong-password",
+			token: validToken,
+			body: ChangeEmailRequest{
+				NewEmail:        "newcustomer@example.com",
+				CurrentPassword: "wrongpassword",
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:  "same-email",
+			token: validToken,
+			body: ChangeEmailRequest{
+				NewEmail:        "customer@example.com",
+				CurrentPassword: "customer123",
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:  "duplicate-email",
+			token: validToken,
+			body: ChangeEmailRequest{
+				NewEmail:        "employee@example.com",
+				CurrentPassword: "customer123",
+			},
+			status: http.StatusAccepted,
+		},
+		{
+			name:  "validation-error-email",
+			token: validToken,
+			body: ChangeEmailRequest{
+				NewEmail:        "invalid-email",
+				CurrentPassword: "customer123",
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "no-token",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			targetURL := "/api/v1/auth/me/email"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPut, testCase.body)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+		})
+	}
+}
+
+func TestChangeEmailTakenAddress(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	token, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	changeEmail := func(newEmail string) *httptest.ResponseRecorder {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/email", http.MethodPut, ChangeEmailRequest{
+			NewEmail:        newEmail,
+			CurrentPassword: "customer123",
+		})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	free := changeEmail("newcustomer@example.com")
+	taken := changeEmail("employee@example.com")
+
+	// Taken addresses can't be told apart from free ones
+	assert.Equal(t, free.Code, taken.Code)
+	assert.JSONEq(t, free.Body.String(), taken.Body.String())
+
+	// No confirmation link is issued for the taken address, and the earlier one is invalidated
+	var count int64
+	err = db.Model(&models.EmailChangeToken{}).Where("user_id = ? AND used_at IS NULL", customerID).Count(&count).Error
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestChangeEmailLockout(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1ms")
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	token, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	changeEmail := func(password string) *httptest.ResponseRecorder {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/email", http.MethodPut, ChangeEmailRequest{
+			NewEmail:        "newcustomer@example.com",
+			CurrentPassword: password,
+		})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// A stolen access token can't be used to guess the password
+	for range 3 {
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, http.StatusUnauthorized, changeEmail("wrongpassword").Code)
+	}
+
+	user, err := models.GetUser(db, customerID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, user.FailedLoginAttempts)
+	assert.True(t, user.IsLocked())
+
+	w := changeEmail("customer123")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+
+	var count int64
+	err = db.Model(&models.EmailChangeToken{}).Where("user_id = ?", customerID).Count(&count).Error
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	takenToken, _ := testingEmailChangeToken(t, db, "employee@example.com", time.Now().Add(time.Hour))
+	expiredToken, _ := testingEmailChangeToken(t, db, "expired@example.com", time.Now().Add(-time.Minute))
+	usedToken, usedChangeToken := testingEmailChangeToken(t, db, "used@example.com", time.Now().Add(time.Hour))
+	err = usedChangeToken.MarkUsed(db)
+	require.NoError(t, err)
+	validToken, _ := testingEmailChangeToken(t, db, "newcustomer@example.com", time.Now().Add(time.Hour))
+	staleToken, _ := testingEmailChangeToken(t, db, "stale@example.com", time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name   string
+		body   any
+		status int
+	}{
+		{
+			name:   "taken-email",
+			body:   ConfirmEmailChangeRequest{Token: takenToken},
+			status: http.StatusConflict,
+		},
+		{
+			name:   "expired-token",
+			body:   ConfirmEmailChangeRequest{Token: expiredToken},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "used-token",
+			body:   ConfirmEmailChangeRequest{Token: usedToken},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown-token",
+			body:   ConfirmEmailChangeRequest{Token: "unknown"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "ok",
+			body:   ConfirmEmailChangeRequest{Token: validToken},
+			status: http.StatusOK,
+		},
+		{
+			name:   "reused-token",
+			body:   ConfirmEmailChangeRequest{Token: validToken},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "email-changed-since",
+			body:   ConfirmEmailChangeRequest{Token: staleToken},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			targetURL := "/api/v1/auth/email/confirm"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+		})
+	}
+
+	user, err := models.GetUserByEmail(db, "newcustomer@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, uuid.MustParse("00000000-0000-0000-0000-000000000003"), user.ID)
+	assert.True(t, user.IsEmailVerified())
+}
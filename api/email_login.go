@@ -0,0 +1,254 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// emailLoginInterval is the minimum time between two login emails to the same user
+const emailLoginInterval = time.Minute
+
+type EmailLoginRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// sendEmailLoginCode issues a new login code, invalidating older ones, and emails
+// it to the user together with a link to the frontend login page
+func sendEmailLoginCode(tx *gorm.DB, user models.User) error {
+	code, err := auth.GenerateEmailLoginCode()
+	if err != nil {
+		return err
+	}
+
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	if err := models.InvalidateUserEmailLoginCodes(tx, user.ID); err != nil {
+		return err
+	}
+
+	ttl := auth.GetEmailLoginTTL()
+	loginCode := models.EmailLoginCode{
+		UserID:    user.ID,
+		CodeHash:  auth.HashEmailLoginCode(code),
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := loginCode.Create(tx); err != nil {
+		return err
+	}
+
+	sendEmail(user.Email, "login-code", map[string]interface{}{
+		"Subject":          "Your login code",
+		"UserName":         user.FirstName,
+		"Code":             code,
+		"LoginLink":        fmt.Sprintf("%s/login/email?token=%s", getFrontendURL(), url.QueryEscape(token)),
+		"ExpiresInMinutes": int(ttl.Minutes()),
+	})
+
+	return nil
+}
+
+// RequestEmailLogin
+//
+//	@Id				RequestEmailLogin
+//	@Summary		Request login by email
+//	@Description	Email a one-time login code and link to the account, for logging in without the password. The response is the same whether or not the account exists, and emails to the same account are throttled.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		EmailLoginRequest	true	"Email address"
+//	@Success		202		{object}	object{message=string}
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		413		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/login/email-code [post]
+func RequestEmailLogin(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req EmailLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := models.GetUserByEmail(tx, req.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = c.Error(err)
+		return
+	}
+
+	if err == nil && user.Active {
+		requested, err := models.EmailLoginRequestedSince(tx, user.ID, time.Now().Add(-emailLoginInterval))
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		if !requested {
+			if err := sendEmailLoginCode(tx, user); err != nil {
+				_ = c.Error(err)
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a login code has been sent"})
+}
+
+type LoginEmailCodeRequest struct {
+	// Email and Code are typed from the email, Token comes from the link in it
+	Email string `json:"email" binding:"required_with=Code,omitempty,email"`
+	Code  string `json:"code" binding:"required_without=Token"`
+	Token string `json:"token" binding:"required_without=Code"`
+}
+
+// LoginEmailCode
+//
+//	@Id				LoginEmailCode
+//	@Summary		Log in with an emailed code
+//	@Description	Exchange the code or link token from the login email for JWT tokens. Users with MFA, and admins and employees who have to set it up, get an MFAChallengeResponse instead, like from Login. Only the latest code works, it expires and stops working after a few wrong attempts, and wrong codes count as failed logins of the account.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		LoginEmailCodeRequest	true	"Email address and code, or link token"
+//	@Success		200		{object}	TokenResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		413		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/login/email-code/verify [post]
+func LoginEmailCode(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req LoginEmailCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	ipAddress := c.ClientIP()
+	ipFailure, err := models.GetIPLoginFailure(tx, ipAddress)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if ipFailure.IsBlocked() {
+		tooManyLoginAttempts(c, *ipFailure.BlockedUntil)
+		return
+	}
+
+	// Every failure gets the same response, so it doesn't reveal whether the
+	// account exists or why the code was refused
+	invalidCode := func() {
+		if err := recordIPLoginFailure(tx, ipAddress); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login code"})
+	}
+
+	var user models.User
+	var loginCode models.EmailLoginCode
+	if req.Token != "" {
+		loginCode, err = models.GetEmailLoginCodeByTokenHash(tx, auth.HashToken(req.Token))
+		if err == nil {
+			user, err = models.GetUser(tx, loginCode.UserID)
+		}
+	} else {
+		user, err = models.GetUserByEmail(tx, req.Email)
+		if err == nil {
+			loginCode, err = models.GetLatestEmailLoginCode(tx, user.ID)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			invalidCode()
+			return
+		}
+		_ = c.Error(err)
+		return
+	}
+
+	// Locked accounts aren't counted against, so attempts can't keep extending the lock
+	if !user.Active || user.IsLocked() || !loginCode.IsValid(auth.EmailLoginMaxAttempts) {
+		invalidCode()
+		return
+	}
+
+	if req.Token == "" && subtle.ConstantTimeCompare([]byte(auth.HashEmailLoginCode(req.Code)), []byte(loginCode.CodeHash)) != 1 {
+		if err := loginCode.RecordAttempt(tx); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		if err := recordFailedLogin(tx, ipAddress, user.Email); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login code"})
+		return
+	}
+
+	if err := loginCode.MarkUsed(tx); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// The code arrived by email, which proves the user owns the address
+	if !user.IsEmailVerified() {
+		if err := user.MarkEmailVerified(tx); err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+
+	// The code stands in for the password only, so MFA is still required
+	secondFactor, err := requiresSecondFactor(tx, user)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	enrollMFA := !secondFactor && user.Role.RequiresMFA()
+
+	if secondFactor || enrollMFA {
+		challenge, err := newMFAChallenge(user, []string{auth.AMREmail}, enrollMFA)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	if user.FailedLoginAttempts > 0 {
+		if err := user.ResetFailedLogins(tx); err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+
+	tokens, err := issueTokens(c, tx, user, uuid.New(), nil, []string{auth.AMREmail}, time.Now())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
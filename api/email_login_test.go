@@ -0,0 +1,324 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// testingEmailLoginCode issues a login code for the user expiring at the given
+// time and returns the code and the token of its link
+func testingEmailLoginCode(t *testing.T, db *gorm.DB, userID uuid.UUID, expiresAt time.Time) (string, string, models.EmailLoginCode) {
+	code, err := auth.GenerateEmailLoginCode()
+	require.NoError(t, err)
+	token, err := auth.GenerateOpaqueToken()
+	require.NoError(t, err)
+
+	loginCode := models.EmailLoginCode{
+		UserID:    userID,
+		CodeHash:  auth.HashEmailLoginCode(code),
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: expiresAt,
+	}
+	err = loginCode.Create(db)
+	require.NoError(t, err)
+
+	return code, token, loginCode
+}
+
+func TestRequestEmailLogin(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+
+	tests := []struct {
+		name   string
+		body   any
+		status int
+		codes  int64
+	}{
+		{
+			name:   "ok",
+			body:   EmailLoginRequest{Email: "customer@example.com"},
+			status: http.StatusAccepted,
+			codes:  1,
+		},
+		{
+			name:   "ok-throttled",
+			body:   EmailLoginRequest{Email: "customer@example.com"},
+			status: http.StatusAccepted,
+			codes:  1,
+		},
+		{
+			name:   "unknown-email",
+			body:   EmailLoginRequest{Email: "nonexistent@example.com"},
+			status: http.StatusAccepted,
+			codes:  1,
+		},
+		{
+			name:   "validation-error-email",
+			body:   EmailLoginRequest{Email: "invalid-email"},
+			status: http.StatusBadRequest,
+			codes:  1,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			targetURL := "/api/v1/auth/login/email-code"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+
+			var codes int64
+			err = db.Model(&models.EmailLoginCode{}).Where("user_id = ?", customerID).Count(&codes).Error
+			require.NoError(t, err)
+			assert.Equal(t, testCase.codes, codes)
+		})
+	}
+}
+
+func TestLoginEmailCode(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+
+	tests := []struct {
+		name      string
+		body      func(code, token string) LoginEmailCodeRequest
+		expiresAt time.Time
+		prepare   func(t *testing.T, loginCode models.EmailLoginCode)
+		status    int
+	}{
+		{
+			name: "ok-code",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{Email: "customer@example.com", Code: code}
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "ok-code-with-spaces",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{Email: "customer@example.com", Code: code[:3] + " " + code[3:]}
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "ok-link",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{Token: token}
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "wrong-code",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{Email: "customer@example.com", Code: "wrong"}
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "expired-code",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{Email: "customer@example.com", Code: code}
+			},
+			expiresAt: time.Now().Add(-time.Minute),
+			status:    http.StatusUnauthorized,
+		},
+		{
+			name: "used-code",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{Email: "customer@example.com", Code: code}
+			},
+			prepare: func(t *testing.T, loginCode models.EmailLoginCode) {
+				err := loginCode.MarkUsed(db)
+				require.NoError(t, err)
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "too-many-attempts",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{Email: "customer@example.com", Code: code}
+			},
+			prepare: func(t *testing.T, loginCode models.EmailLoginCode) {
+				for range auth.EmailLoginMaxAttempts {
+					err := loginCode.RecordAttempt(db)
+					require.NoError(t, err)
+				}
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown-email",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{Email: "nonexistent@example.com", Code: code}
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown-token",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{Token: "unknown"}
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "inactive-user",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{Token: token}
+			},
+			prepare: func(t *testing.T, loginCode models.EmailLoginCode) {
+				err := db.Model(&models.User{}).Where("id = ?", customerID).Update("active", false).Error
+				require.NoError(t, err)
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "validation-error",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{}
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "validation-error-email",
+			body: func(code, token string) LoginEmailCodeRequest {
+				return LoginEmailCodeRequest{Code: code}
+			},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			expiresAt := testCase.expiresAt
+			if expiresAt.IsZero() {
+				expiresAt = time.Now().Add(time.Hour)
+			}
+			code, token, loginCode := testingEmailLoginCode(t, db, customerID, expiresAt)
+			if testCase.prepare != nil {
+				testCase.prepare(t, loginCode)
+			}
+
+			targetURL := "/api/v1/auth/login/email-code/verify"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body(code, token))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"access_token":  xtesting.ValueNotEqual(""),
+				"refresh_token": xtesting.ValueNotEqual(""),
+				"id_token":      xtesting.ValueNotEqual(""),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+		})
+	}
+}
+
+func TestLoginEmailCodeIsSingleUse(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	code, _, _ := testingEmailLoginCode(t, db, customerID, time.Now().Add(time.Hour))
+
+	body := LoginEmailCodeRequest{Email: "customer@example.com", Code: code}
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/email-code/verify", http.MethodPost, body)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/login/email-code/verify", http.MethodPost, body)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestLoginEmailCodeOnlyLatest(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	code, _, _ := testingEmailLoginCode(t, db, customerID, time.Now().Add(time.Hour))
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/email-code", http.MethodPost, EmailLoginRequest{
+		Email: "customer@example.com",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/login/email-code/verify", http.MethodPost, LoginEmailCodeRequest{
+		Email: "customer@example.com",
+		Code:  code,
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestLoginEmailCodeRequiresMFA(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	testingEnableMFA(t, db, customerID)
+	_, token, _ := testingEmailLoginCode(t, db, customerID, time.Now().Add(time.Hour))
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/email-code/verify", http.MethodPost, LoginEmailCodeRequest{
+		Token: token,
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ignoreResp := xtesting.ValuesCheckers{
+		"mfa_token": xtesting.ValueNotEqual(""),
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	xtesting.AssertGoldenJSON(t, w, ignoreResp)
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	tokenTypeHintAccess  = "access_token"
+	tokenTypeHintRefresh = "refresh_token"
+
+	// tokenScope is what every token grants, there are no narrower scopes
+	tokenScope = "openid email profile"
+)
+
+type IntrospectRequest struct {
+	Token         string `json:"token" form:"token" binding:"required"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint" binding:"omitempty,oneof=access_token refresh_token"`
+}
+
+// IntrospectionResponse follows RFC 7662. Inactive tokens only report active=false,
+// without telling why.
+type IntrospectionResponse struct {
+	Active    bool            `json:"active"`
+	Subject   string          `json:"sub,omitempty"`
+	Username  string          `json:"username,omitempty"`
+	Role      models.UserRole `json:"role,omitempty"`
+	Scope     string          `json:"scope,omitempty"`
+	TokenType string          `json:"token_type,omitempty"`
+	ExpiresAt int64           `json:"exp,omitempty"`
+	IssuedAt  int64           `json:"iat,omitempty"`
+	Issuer    string          `json:"iss,omitempty"`
+	Audience  []string        `json:"aud,omitempty"`
+	JTI       string          `json:"jti,omitempty"`
+	AMR       []string        `json:"amr,omitempty"`
+}
+
+// authenticateClient checks the client credentials sent either with HTTP Basic
+// authentication or as client_id and client_secret form parameters
+func authenticateClient(c *gin.Context) bool {
+	clientID, secret, ok := c.Request.BasicAuth()
+	if ok {
+		// OAuth clients form encode the credentials before Basic encoding them
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err != nil {
+			return false
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return false
+		}
+	} else {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+
+	if clientID == "" {
+		return false
+	}
+	return auth.AuthenticateClient(clientID, secret)
+}
+
+// isTokenError reports whether the token was rejected, as opposed to the check failing
+func isTokenError(err error) bool {
+	return errors.Is(err, auth.ErrInvalidToken) ||
+		errors.Is(err, auth.ErrExpiredToken) ||
+		errors.Is(err, auth.ErrWrongTokenType) ||
+		errors.Is(err, auth.ErrRevokedToken)
+}
+
+// introspectToken checks the token as the type named by the hint first, falling
+// back to the other type as RFC 7662 requires
+func introspectToken(tx *gorm.DB, token string, hint string) (IntrospectionResponse, error) {
+	inactive := IntrospectionResponse{Active: false}
+
+	tokenTypes := []auth.TokenType{auth.TokenTypeAccess, auth.TokenTypeRefresh}
+	if hint == tokenTypeHintRefresh {
+		tokenTypes = []auth.TokenType{auth.TokenTypeRefresh, auth.TokenTypeAccess}
+	}
+
+	var claims *auth.Claims
+	var err error
+	for _, tokenType := range tokenTypes {
+		claims, err = auth.ValidateToken(token, tokenType)
+		if err == nil || !errors.Is(err, auth.ErrWrongTokenType) {
+			break
+		}
+	}
+	if err != nil {
+		if isTokenError(err) {
+			return inactive, nil
+		}
+		return inactive, err
+	}
+
+	user, err := models.GetUser(tx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return inactive, nil
+		}
+		return inactive, err
+	}
+
+	if !user.Active {
+		return inactive, nil
+	}
+
+	tokenTypeHint := tokenTypeHintAccess
+	if claims.TokenUse == auth.TokenTypeRefresh {
+		tokenTypeHint = tokenTypeHintRefresh
+
+		active, err := models.IsRefreshTokenActive(tx, auth.HashToken(token))
+		if err != nil {
+			return inactive, err
+		}
+		if !active {
+			return inactive, nil
+		}
+	} else if isAccessTokenRevoked(claims, user) {
+		return inactive, nil
+	}
+
+	if !satisfiesMFAPolicy(claims, user) {
+		return inactive, nil
+	}
+
+	return IntrospectionResponse{
+		Active:    true,
+		Subject:   claims.UserID.String(),
+		Username:  claims.Email,
+		Role:      user.Role,
+		Scope:     tokenScope,
+		TokenType: tokenTypeHint,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		JTI:       claims.ID,
+		AMR:       claims.AMR,
+	}, nil
+}
+
+// Introspect
+//
+//	@Id				Introspect
+//	@Summary		Introspect token
+//	@Description	RFC 7662 token introspection for resource servers. Accepts access and refresh tokens; token_type in the response is access_token or refresh_token. The client authenticates with HTTP Basic or client_id and client_secret form parameters.
+//	@Tags			auth
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Param			token			formData	string	true	"Token to introspect"
+//	@Param			token_type_hint	formData	string	false	"access_token or refresh_token"
+//	@Success		200				{object}	IntrospectionResponse
+//	@Failure		400				{object}	middleware.HttpError
+//	@Failure		401				{object}	middleware.HttpError
+//	@Failure		429				{object}	middleware.HttpError
+//	@Failure		500				{object}	middleware.HttpError
+//	@Failure		503				{object}	middleware.HttpError
+//	@Router			/introspect [post]
+func Introspect(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	if !authenticateClient(c) {
+		c.Header("WWW-Authenticate", `Basic realm="introspect"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		return
+	}
+
+	var req IntrospectRequest
+	if err := c.ShouldBind(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response, err := introspectToken(tx, req.Token, req.TokenTypeHint)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
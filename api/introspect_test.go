@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntrospect(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	t.Setenv("INTROSPECTION_CLIENTS", "gateway:gateway-secret")
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	accessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	refreshToken, _ := TestingRefreshToken(t, db, customerID, "customer@example.com")
+	rotatedRefreshToken, rotatedStoredToken := TestingRefreshToken(t, db, customerID, "customer@example.com")
+	err = rotatedStoredToken.Revoke(db)
+	require.NoError(t, err)
+
+	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com")
+
+	tests := []struct {
+		name         string
+		form         url.Values
+		clientID     string
+		clientSecret string
+		status       int
+	}{
+		{
+			name:         "ok-access-token",
+			form:         url.Values{"token": {accessToken}},
+			clientID:     "gateway",
+			clientSecret: "gateway-secret",
+			status:       http.StatusOK,
+		},
+		{
+			name:         "ok-refresh-token",
+			form:         url.Values{"token": {refreshToken}, "token_type_hint": {"refresh_token"}},
+			clientID:     "gateway",
+			clientSecret: "gateway-secret",
+			status:       http.StatusOK,
+		},
+		{
+			name:         "ok-wrong-hint",
+			form:         url.Values{"token": {refreshToken}, "token_type_hint": {"access_token"}},
+			clientID:     "gateway",
+			clientSecret: "gateway-secret",
+			status:       http.StatusOK,
+		},
+		{
+			name: "ok-client-secret-post",
+			form: url.Values{
+				"token":         {accessToken},
+				"client_id":     {"gateway"},
+				"client_secret": {"gateway-secret"},
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:         "rotated-refresh-token",
+			form:         url.Values{"token": {rotatedRefreshToken}},
+			clientID:     "gateway",
+			clientSecret: "gateway-secret",
+			status:       http.StatusOK,
+		},
+		{
+			name:         "admin-token-without-mfa",
+			form:         url.Values{"token": {adminToken}},
+			clientID:     "gateway",
+			clientSecret: "gateway-secret",
+			status:       http.StatusOK,
+		},
+		{
+			name:         "invalid-token",
+			form:         url.Values{"token": {"invalid.jwt.token"}},
+			clientID:     "gateway",
+			clientSecret: "gateway-secret",
+			status:       http.StatusOK,
+		},
+		{
+			name:         "wrong-client-secret",
+			form:         url.Values{"token": {accessToken}},
+			clientID:     "gateway",
+			clientSecret: "wrong-secret",
+			status:       http.StatusUnauthorized,
+		},
+		{
+			name:   "no-client-credentials",
+			form:   url.Values{"token": {accessToken}},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:         "no-token",
+			form:         url.Values{},
+			clientID:     "gateway",
+			clientSecret: "gateway-secret",
+			status:       http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			targetURL := "/api/v1/auth/introspect"
+
+			req := httptest.NewRequest(http.MethodPost, targetURL, strings.NewReader(testCase.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if testCase.clientID != "" {
+				req.SetBasicAuth(testCase.clientID, testCase.clientSecret)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			if testCase.status != http.StatusOK {
+				xtesting.AssertGoldenJSON(t, w)
+				return
+			}
+
+			// Timestamps and token IDs change with every run, so they are checked here
+			// and left out of the golden file
+			var response IntrospectionResponse
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			require.NoError(t, err)
+
+			if response.Active {
+				assert.WithinDuration(t, time.Now(), time.Unix(response.IssuedAt, 0), time.Minute)
+				assert.Greater(t, response.ExpiresAt, response.IssuedAt)
+				assert.NotEmpty(t, response.JTI)
+				response.IssuedAt, response.ExpiresAt, response.JTI = 0, 0, ""
+			}
+
+			body, err := json.Marshal(response)
+			require.NoError(t, err)
+			xtesting.AssertGoldenJSONWithName(t, body, "")
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type SigningKeyResponse struct {
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Algorithm string     `json:"algorithm"`
+	Active    bool       `json:"active"`
+	RetiredAt *time.Time `json:"retired_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func newSigningKeyResponse(key models.SigningKey) SigningKeyResponse {
+	return SigningKeyResponse{
+		ID:        key.ID,
+		CreatedAt: key.CreatedAt,
+		Algorithm: key.Algorithm,
+		Active:    key.Active,
+		RetiredAt: key.RetiredAt,
+		ExpiresAt: key.ExpiresAt,
+	}
+}
+
+// KeysList
+//
+//	@Id				KeysList
+//	@Summary		List signing keys
+//	@Description	List the active signing key and the retired keys that are still accepted (admin endpoint)
+//	@Tags			keys
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	[]SigningKeyResponse
+//	@Failure		401	{object}	middleware.HttpError
+//	@Failure		403	{object}	middleware.HttpError
+//	@Failure		500	{object}	middleware.HttpError
+//	@Router			/keys [get]
+func KeysList(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	keys, err := models.GetSigningKeys(tx)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := []SigningKeyResponse{}
+	for _, key := range keys {
+		response = append(response, newSigningKeyResponse(key))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// KeysRotate
+//
+//	@Id				KeysRotate
+//	@Summary		Rotate signing key
+//	@Description	Generate a new signing key and use it for new tokens. The previous key keeps verifying the tokens it signed until they expire (admin endpoint).
+//	@Tags			keys
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		201	{object}	SigningKeyResponse
+//	@Failure		401	{object}	middleware.HttpError
+//	@Failure		403	{object}	middleware.HttpError
+//	@Failure		500	{object}	middleware.HttpError
+//	@Router			/keys/rotate [post]
+func KeysRotate(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	signingKey, err := auth.RotateSigningKey()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	slog.Info("Rotated signing key", "kid", signingKey.ID, "user_id", GetContextUserID(c))
+
+	key, err := models.GetSigningKey(tx, signingKey.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newSigningKeyResponse(key))
+}
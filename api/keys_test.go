@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeysList(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{
+			name:   "ok",
+			token:  adminToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "forbidden-customer",
+			token:  customerToken,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "no-token",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			targetURL := "/api/v1/auth/keys"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodGet, nil)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.GenerateValueCheckersForArrays(map[string]xtesting.ValueChecker{
+				"created_at": xtesting.ValueTimeInPastDuration(time.Minute),
+			}, 1)
+
+			assert.Equal(t, testCase.status, w.Code)
+			if testCase.status == http.StatusOK {
+				xtesting.AssertGoldenJSON(t, w, ignoreResp)
+			} else {
+				xtesting.AssertGoldenJSON(t, w)
+			}
+		})
+	}
+}
+
+func TestKeysRotate(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{
+			name:   "ok",
+			token:  adminToken,
+			status: http.StatusCreated,
+		},
+		{
+			name:   "ok-signed-with-retired-key",
+			token:  adminToken,
+			status: http.StatusCreated,
+		},
+		{
+			name:   "forbidden-customer",
+			token:  customerToken,
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			targetURL := "/api/v1/auth/keys/rotate"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, nil)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"id":         xtesting.ValueRegexp(`^[A-Za-z0-9_-]{16}$`),
+				"created_at": xtesting.ValueTimeInPastDuration(time.Second),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			if testCase.status == http.StatusCreated {
+				xtesting.AssertGoldenJSON(t, w, ignoreResp)
+			} else {
+				xtesting.AssertGoldenJSON(t, w)
+			}
+		})
+	}
+}
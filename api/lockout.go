@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tooManyLoginAttempts tells a blocked IP address when it may try to log in again
+func tooManyLoginAttempts(c *gin.Context, until time.Time) {
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
+}
+
+// recordIPLoginFailure counts a failed login from the IP address and blocks the
+// address once it reaches its threshold
+func recordIPLoginFailure(tx *gorm.DB, ipAddress string) error {
+	window := auth.GetLoginLockoutDuration()
+
+	ipFailure, err := models.RecordIPLoginFailure(tx, ipAddress, window)
+	if err != nil {
+		return err
+	}
+	if ipFailure.FailedAttempts >= auth.GetIPLoginLockoutThreshold() {
+		if err := ipFailure.BlockUntil(tx, time.Now().Add(window)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordFailedLogin counts the failure against the IP address and the account,
+// if there is one. The account has to wait longer with every failure, and is
+// locked and its owner notified once it reaches its threshold.
+func recordFailedLogin(tx *gorm.DB, ipAddress string, email string) error {
+	if err := recordIPLoginFailure(tx, ipAddress); err != nil {
+		return err
+	}
+
+	user, err := models.RecordFailedLogin(tx, email, auth.GetLoginLockoutDuration())
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	delay, lockout := auth.LoginBackoff(user.FailedLoginAttempts)
+	lockedUntil := time.Now().Add(delay)
+	if err := user.LockUntil(tx, lockedUntil); err != nil {
+		return err
+	}
+
+	if lockout {
+		sendEmail(user.Email, "account-locked", map[string]interface{}{
+			"Subject":     "Your account has been temporarily locked",
+			"UserName":    user.FirstName,
+			"Attempts":    user.FailedLoginAttempts,
+			"LockedUntil": lockedUntil.UTC().Format(time.RFC1123),
+			"ResetLink":   fmt.Sprintf("%s/forgot-password", getFrontendURL()),
+		})
+	}
+
+	return nil
+}
+
+// checkUserPassword verifies the password of a signed in user confirming a
+// change, responding itself if the account is locked or the password is wrong.
+// Wrong passwords count as failed logins, so a stolen access token can't be used
+// to guess the password. The failed logins are only reset by resetFailedLogins
+// once every other factor has been checked as well.
+func checkUserPassword(c *gin.Context, tx *gorm.DB, user *models.User, password string) bool {
+	if user.IsLocked() {
+		tooManyLoginAttempts(c, *user.LockedUntil)
+		return false
+	}
+
+	if err := user.CheckPassword(password); err != nil {
+		if err := recordFailedLogin(tx, c.ClientIP(), user.Email); err != nil {
+			_ = c.Error(err)
+			return false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return false
+	}
+
+	return true
+}
+
+// resetFailedLogins clears the failed logins of a user who passed every check
+func resetFailedLogins(c *gin.Context, tx *gorm.DB, user *models.User) bool {
+	if user.FailedLoginAttempts > 0 {
+		if err := user.ResetFailedLogins(tx); err != nil {
+			_ = c.Error(err)
+			return false
+		}
+	}
+
+	return true
+}
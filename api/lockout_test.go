@@ -0,0 +1,292 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginLockout(t *testing.T) {
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1ms")
+
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
+
+	wrongPassword := LoginRequest{Email: "customer@example.com", Password: "wrongpassword"}
+	rightPassword := LoginRequest{Email: "customer@example.com", Password: "customer123"}
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		token  string
+		body   any
+		status int
+	}{
+		{
+			name:   "first-failure",
+			method: http.MethodPost,
+			url:    "/api/v1/auth/login",
+			body:   wrongPassword,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "second-failure",
+			method: http.MethodPost,
+			url:    "/api/v1/auth/login",
+			body:   wrongPassword,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "third-failure",
+			method: http.MethodPost,
+			url:    "/api/v1/auth/login",
+			body:   wrongPassword,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "locked",
+			method: http.MethodPost,
+			url:    "/api/v1/auth/login",
+			body:   rightPassword,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "unlock",
+			method: http.MethodPost,
+			url:    "/api/v1/auth/users/00000000-0000-0000-0000-000000000003/unlock",
+			token:  adminToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "unlocked",
+			method: http.MethodPost,
+			url:    "/api/v1/auth/login",
+			body:   rightPassword,
+			status: http.StatusOK,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Wait out the backoff between the failures
+			time.Sleep(10 * time.Millisecond)
+
+			req := xtesting.NewTestingRequest(t, testCase.url, testCase.method, testCase.body)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"updated_at":    xtesting.ValueTimeInPastDuration(time.Second),
+				"access_token":  xtesting.ValueNotEqual(""),
+				"refresh_token": xtesting.ValueNotEqual(""),
+				"id_token":      xtesting.ValueNotEqual(""),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			if testCase.status == http.StatusOK {
+				xtesting.AssertGoldenJSON(t, w, ignoreResp)
+			} else {
+				xtesting.AssertGoldenJSON(t, w)
+			}
+		})
+	}
+}
+
+func TestLoginBackoff(t *testing.T) {
+	t.Setenv("LOGIN_BACKOFF_BASE", "200ms")
+
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: "wrongpassword",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Even the right password fails until the delay after a failure is over
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: "customer123",
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	time.Sleep(300 * time.Millisecond)
+
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: "customer123",
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLoginIPBlock(t *testing.T) {
+	t.Setenv("IP_LOGIN_LOCKOUT_THRESHOLD", "2")
+
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	// Failures are counted per address across accounts, including unknown ones
+	for _, email := range []string{"nonexistent@example.com", "other@example.com"} {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+			Email:    email,
+			Password: "wrongpassword",
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: "customer123",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestLoginIPBlockForwardedFor(t *testing.T) {
+	t.Setenv("IP_LOGIN_LOCKOUT_THRESHOLD", "2")
+
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	login := func(email, password, forwardedFor string) *httptest.ResponseRecorder {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+			Email:    email,
+			Password: password,
+		})
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// X-Forwarded-For from a peer that isn't a trusted proxy doesn't change the address
+	require.Equal(t, http.StatusUnauthorized, login("nonexistent@example.com", "wrongpassword", "198.51.100.1").Code)
+	require.Equal(t, http.StatusUnauthorized, login("other@example.com", "wrongpassword", "198.51.100.2").Code)
+
+	failure, err := models.GetIPLoginFailure(db, "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, failure.FailedAttempts)
+	assert.True(t, failure.IsBlocked())
+
+	w := login("customer@example.com", "customer123", "198.51.100.3")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestUsersUnlock(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name   string
+		token  string
+		userID string
+		status int
+	}{
+		{
+			name:   "ok",
+			token:  adminToken,
+			userID: "00000000-0000-0000-0000-000000000003",
+			status: http.StatusOK,
+		},
+		{
+			name:   "not-found",
+			token:  adminToken,
+			userID: "00000000-0000-0000-0000-999999999999",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "forbidden-customer",
+			token:  customerToken,
+			userID: "00000000-0000-0000-0000-000000000002",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "no-token",
+			userID: "00000000-0000-0000-0000-000000000003",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			user, err := models.GetUser(db, customerID)
+			require.NoError(t, err)
+			err = user.LockUntil(db, time.Now().Add(time.Hour))
+			require.NoError(t, err)
+
+			targetURL := fmt.Sprintf("/api/v1/auth/users/%s/unlock", testCase.userID)
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, nil)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"updated_at": xtesting.ValueTimeInPastDuration(time.Second),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			if testCase.status == http.StatusOK {
+				xtesting.AssertGoldenJSON(t, w, ignoreResp)
+			} else {
+				xtesting.AssertGoldenJSON(t, w)
+			}
+		})
+	}
+}
@@ -0,0 +1,779 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MFAChallengeResponse is returned by Login instead of tokens when the user has
+// MFA enabled. The MFA token is exchanged for tokens at /login/mfa. Users whose
+// role requires MFA but who have none get MFAEnrollmentRequired, and set up TOTP
+// at /login/mfa/totp with the MFA token instead.
+type MFAChallengeResponse struct {
+	MFARequired           bool   `json:"mfa_required"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string `json:"mfa_token"`
+	ExpiresIn             int    `json:"expires_in"` // seconds
+}
+
+// errMFARequiredForRole refuses to remove the last second factor of a user whose
+// role requires MFA
+const errMFARequiredForRole = "MFA is required for your role"
+
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	// QRCode is the otpauth URI as a PNG data URI
+	QRCode string `json:"qr_code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAEnrollmentResponse is returned when TOTP was set up during login. The
+// recovery codes are only shown once.
+type MFAEnrollmentResponse struct {
+	TokenResponse
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// newMFAChallenge issues the token a user with MFA has to present together with
+// their code. amr are the methods of the first factor, which the tokens issued for
+// the MFA token will list too. enroll marks users who have to set up MFA first.
+func newMFAChallenge(user models.User, amr []string, enroll bool) (MFAChallengeResponse, error) {
+	token, claims, err := auth.NewToken(user.ID, user.Email, auth.TokenTypeMFAChallenge, auth.WithAMR(amr...))
+	if err != nil {
+		return MFAChallengeResponse{}, err
+	}
+
+	return MFAChallengeResponse{
+		MFARequired:           true,
+		MFAEnrollmentRequired: enroll,
+		MFAToken:              token,
+		ExpiresIn:             int(claims.ExpiresAt.Sub(claims.IssuedAt.Time).Seconds()),
+	}, nil
+}
+
+// withSecondFactor adds the method of the second factor to those of the first
+func withSecondFactor(amr []string, method string) []string {
+	return append(slices.Clone(amr), method, auth.AMRMultiFactor)
+}
+
+// verifyMFACode accepts a current TOTP code or an unused recovery code of the user.
+// Either can only be used once. Codes of a secret whose enrollment hasn't been
+// confirmed are refused, e.g. for users whose second factor is a passkey.
+func verifyMFACode(tx *gorm.DB, user *models.User, code string) (bool, error) {
+	if !user.HasMFA() {
+		return false, nil
+	}
+
+	secret, err := user.GetTOTPSecret()
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := auth.ValidateTOTP(secret, code, time.Now()); ok {
+		return user.UseTOTPStep(tx, step)
+	}
+
+	return models.UseRecoveryCode(tx, user.ID, auth.HashRecoveryCode(code))
+}
+
+// issueRecoveryCodes replaces the recovery codes of the user with new ones
+func issueRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	codeHashes := make([]string, len(codes))
+	for i, code := range codes {
+		codeHashes[i] = auth.HashRecoveryCode(code)
+	}
+
+	if err := models.ReplaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// getMFAChallengeUser returns the user the MFA token was issued to and its claims,
+// responding itself if the token is invalid or the user can't log in with it
+// anymore. With enroll the user has to be one who needs to set up MFA, otherwise
+// one who has it.
+func getMFAChallengeUser(c *gin.Context, tx *gorm.DB, mfaToken string, enroll bool) (models.User, *auth.Claims, bool) {
+	claims, err := auth.ValidateToken(mfaToken, auth.TokenTypeMFAChallenge)
+	if err != nil {
+		if isTokenError(err) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+			return models.User{}, nil, false
+		}
+		_ = c.Error(err)
+		return models.User{}, nil, false
+	}
+
+	user, err := models.GetUser(tx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+			return models.User{}, nil, false
+		}
+		_ = c.Error(err)
+		return models.User{}, nil, false
+	}
+
+	secondFactor, err := requiresSecondFactor(tx, user)
+	if err != nil {
+		_ = c.Error(err)
+		return models.User{}, nil, false
+	}
+
+	// Enrolling must not be possible once there is a second factor, or the password
+	// alone would be enough to replace it
+	valid := secondFactor
+	if enroll {
+		valid = !secondFactor && user.Role.RequiresMFA()
+	}
+
+	if !user.Active || !valid || isAccessTokenRevoked(claims, user) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return models.User{}, nil, false
+	}
+
+	return user, claims, true
+}
+
+// consumeMFAChallenge revokes the MFA token once the second factor was accepted,
+// so it can't be exchanged for tokens again. It responds itself and returns false
+// if another request, possibly on another replica, has already used the token.
+func consumeMFAChallenge(c *gin.Context, tx *gorm.DB, claims *auth.Claims) bool {
+	revokedToken := models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	consumed, err := revokedToken.Consume(tx)
+	if err != nil {
+		_ = c.Error(err)
+		return false
+	}
+	if !consumed {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return false
+	}
+
+	auth.RevokeToken(claims.ID, claims.ExpiresAt.Time)
+	return true
+}
+
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	// Code is a TOTP or recovery code, not needed when a passkey is presented
+	Code string `json:"code" binding:"required_without=Credential"`
+	// SessionToken and Credential are the session token of
+	// /login/mfa/passkey/options and the result of navigator.credentials.get
+	SessionToken string           `json:"session_token" binding:"required_with=Credential"`
+	Credential   *json.RawMessage `json:"credential" swaggertype:"object"`
+}
+
+// LoginMFA
+//
+//	@Id				LoginMFA
+//	@Summary		Complete MFA login
+//	@Description	Exchange the MFA token returned by Login and a TOTP or recovery code for JWT tokens. The MFA token can only be exchanged once. Instead of a code, a passkey assertion started at /login/mfa/passkey/options can be sent. Wrong codes count as failed logins of the account.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		LoginMFARequest	true	"MFA token and code or passkey assertion"
+//	@Success		200		{object}	TokenResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/login/mfa [post]
+func LoginMFA(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req LoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	ipAddress := c.ClientIP()
+	ipFailure, err := models.GetIPLoginFailure(tx, ipAddress)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if ipFailure.IsBlocked() {
+		tooManyLoginAttempts(c, *ipFailure.BlockedUntil)
+		return
+	}
+
+	user, claims, ok := getMFAChallengeUser(c, tx, req.MFAToken, false)
+	if !ok {
+		return
+	}
+
+	// A locked account fails like a wrong code, and isn't counted against the
+	// account, so attempts can't keep extending its lock
+	if user.IsLocked() {
+		if err := recordIPLoginFailure(tx, ipAddress); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		return
+	}
+
+	var valid bool
+	method := auth.AMROTP
+	if req.Credential != nil {
+		method = auth.AMRHardwareKey
+		valid, err = verifyMFAPasskey(tx, user, req.SessionToken, *req.Credential)
+	} else {
+		valid, err = verifyMFACode(tx, &user, req.Code)
+	}
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !valid {
+		if err := recordFailedLogin(tx, ipAddress, user.Email); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		return
+	}
+
+	if !consumeMFAChallenge(c, tx, claims) {
+		return
+	}
+
+	if user.FailedLoginAttempts > 0 {
+		if err := user.ResetFailedLogins(tx); err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+
+	tokens, err := issueTokens(c, tx, user, uuid.New(), nil, withSecondFactor(claims.AMR, method), time.Now())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+type LoginTOTPEnrollRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// LoginTOTPEnroll
+//
+//	@Id				LoginTOTPEnroll
+//	@Summary		Start TOTP enrollment during login
+//	@Description	Generate a TOTP secret for an admin or employee who has to set up MFA, with the MFA token returned by Login together with mfa_enrollment_required. Confirm it at /login/mfa/totp/confirm to finish logging in.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		LoginTOTPEnrollRequest	true	"MFA token"
+//	@Success		200		{object}	TOTPEnrollmentResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/login/mfa/totp [post]
+func LoginTOTPEnroll(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req LoginTOTPEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, _, ok := getMFAChallengeUser(c, tx, req.MFAToken, true)
+	if !ok {
+		return
+	}
+
+	enrollment, err := startTOTPEnrollment(tx, &user)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+type LoginTOTPConfirmRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// LoginTOTPConfirm
+//
+//	@Id				LoginTOTPConfirm
+//	@Summary		Confirm TOTP enrollment during login
+//	@Description	Enable MFA with a code from the authenticator app and exchange the MFA token for JWT tokens, which can only be done once. Also returns one-time recovery codes, which are only shown once. Wrong codes count as failed logins of the account.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		LoginTOTPConfirmRequest	true	"MFA token and TOTP code"
+//	@Success		200		{object}	MFAEnrollmentResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/login/mfa/totp/confirm [post]
+func LoginTOTPConfirm(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req LoginTOTPConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	ipFailure, err := models.GetIPLoginFailure(tx, c.ClientIP())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if ipFailure.IsBlocked() {
+		tooManyLoginAttempts(c, *ipFailure.BlockedUntil)
+		return
+	}
+
+	user, claims, ok := getMFAChallengeUser(c, tx, req.MFAToken, true)
+	if !ok {
+		return
+	}
+
+	codes, ok := confirmTOTPEnrollment(c, tx, &user, req.Code)
+	if !ok {
+		return
+	}
+
+	if !consumeMFAChallenge(c, tx, claims) {
+		return
+	}
+
+	if user.FailedLoginAttempts > 0 {
+		if err := user.ResetFailedLogins(tx); err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+
+	tokens, err := issueTokens(c, tx, user, uuid.New(), nil, withSecondFactor(claims.AMR, auth.AMROTP), time.Now())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAEnrollmentResponse{
+		TokenResponse: tokens,
+		RecoveryCodes: codes,
+	})
+}
+
+type MFAStatusResponse struct {
+	Enabled                bool  `json:"enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// MFAStatus
+//
+//	@Id				MFAStatus
+//	@Summary		Get MFA status
+//	@Description	Whether the current user has MFA enabled, and how many unused recovery codes they have left
+//	@Tags			mfa
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	MFAStatusResponse
+//	@Failure		401	{object}	middleware.HttpError
+//	@Failure		500	{object}	middleware.HttpError
+//	@Router			/me/mfa [get]
+func MFAStatus(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := GetContextUserID(c)
+
+	user, err := models.GetUser(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	remaining, err := models.CountUnusedRecoveryCodes(tx, user.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAStatusResponse{
+		Enabled:                user.HasMFA(),
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+// startTOTPEnrollment generates a TOTP secret for the user, replacing an
+// unconfirmed one
+func startTOTPEnrollment(tx *gorm.DB, user *models.User) (TOTPEnrollmentResponse, error) {
+	key, err := auth.GenerateTOTPKey(user.Email)
+	if err != nil {
+		return TOTPEnrollmentResponse{}, err
+	}
+
+	qrCode, err := auth.TOTPQRCode(key)
+	if err != nil {
+		return TOTPEnrollmentResponse{}, err
+	}
+
+	if err := user.StartTOTPEnrollment(tx, key.Secret()); err != nil {
+		return TOTPEnrollmentResponse{}, err
+	}
+
+	return TOTPEnrollmentResponse{
+		Secret:     key.Secret(),
+		OTPAuthURI: key.URL(),
+		QRCode:     qrCode,
+	}, nil
+}
+
+// confirmTOTPEnrollment enables MFA with a code of the secret being enrolled and
+// returns new recovery codes, responding itself if the code is wrong. Wrong codes
+// count as failed logins, so the code can't be guessed within the rate limit.
+func confirmTOTPEnrollment(c *gin.Context, tx *gorm.DB, user *models.User, code string) ([]string, bool) {
+	if user.TOTPSecret == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "TOTP enrollment has not been started"})
+		return nil, false
+	}
+
+	if user.IsLocked() {
+		tooManyLoginAttempts(c, *user.LockedUntil)
+		return nil, false
+	}
+
+	secret, err := user.GetTOTPSecret()
+	if err != nil {
+		_ = c.Error(err)
+		return nil, false
+	}
+
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		if err := recordFailedLogin(tx, c.ClientIP(), user.Email); err != nil {
+			_ = c.Error(err)
+			return nil, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid MFA code"})
+		return nil, false
+	}
+
+	if err := user.EnableTOTP(tx, step); err != nil {
+		_ = c.Error(err)
+		return nil, false
+	}
+
+	codes, err := issueRecoveryCodes(tx, user.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return nil, false
+	}
+
+	sendEmail(user.Email, "mfa-enabled", map[string]interface{}{
+		"Subject":  "Two-factor authentication enabled",
+		"UserName": user.FirstName,
+	})
+
+	return codes, true
+}
+
+type TOTPEnrollRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// TOTPEnroll
+//
+//	@Id				TOTPEnroll
+//	@Summary		Start TOTP enrollment
+//	@Description	Generate a TOTP secret for the current user, to be added to an authenticator app by its otpauth URI or QR code. Logins don't require codes until the secret is confirmed. Starting again replaces an unconfirmed secret. Wrong passwords count as failed logins of the account.
+//	@Tags			mfa
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		TOTPEnrollRequest	true	"Current password"
+//	@Success		200		{object}	TOTPEnrollmentResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		409		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/me/mfa/totp [post]
+func TOTPEnroll(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := GetContextUserID(c)
+
+	var req TOTPEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := models.GetUser(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if !checkUserPassword(c, tx, &user, req.Password) {
+		return
+	}
+	if !resetFailedLogins(c, tx, &user) {
+		return
+	}
+
+	if user.HasMFA() {
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+		return
+	}
+
+	enrollment, err := startTOTPEnrollment(tx, &user)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+type TOTPConfirmRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TOTPConfirm
+//
+//	@Id				TOTPConfirm
+//	@Summary		Confirm TOTP enrollment
+//	@Description	Enable MFA with a code from the authenticator app. Returns one-time recovery codes, which are only shown once. Wrong codes count as failed logins of the account.
+//	@Tags			mfa
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		TOTPConfirmRequest	true	"TOTP code"
+//	@Success		200		{object}	RecoveryCodesResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		409		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Router			/me/mfa/totp/confirm [post]
+func TOTPConfirm(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := GetContextUserID(c)
+
+	var req TOTPConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := models.GetUser(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if user.HasMFA() {
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+		return
+	}
+
+	codes, ok := confirmTOTPEnrollment(c, tx, &user, req.Code)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+type MFAVerifyRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// checkMFARequest verifies the password and MFA code of a request changing the
+// MFA settings, responding itself if they are wrong. Wrong ones count as failed
+// logins, so a stolen access token can't be used to guess the code.
+func checkMFARequest(c *gin.Context, tx *gorm.DB, user *models.User, req MFAVerifyRequest) bool {
+	if !user.HasMFA() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is not enabled"})
+		return false
+	}
+
+	if user.IsLocked() {
+		tooManyLoginAttempts(c, *user.LockedUntil)
+		return false
+	}
+
+	if err := user.CheckPassword(req.Password); err != nil {
+		if err := recordFailedLogin(tx, c.ClientIP(), user.Email); err != nil {
+			_ = c.Error(err)
+			return false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return false
+	}
+
+	valid, err := verifyMFACode(tx, user, req.Code)
+	if err != nil {
+		_ = c.Error(err)
+		return false
+	}
+	if !valid {
+		if err := recordFailedLogin(tx, c.ClientIP(), user.Email); err != nil {
+			_ = c.Error(err)
+			return false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		return false
+	}
+
+	if user.FailedLoginAttempts > 0 {
+		if err := user.ResetFailedLogins(tx); err != nil {
+			_ = c.Error(err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// MFADisable
+//
+//	@Id				MFADisable
+//	@Summary		Disable MFA
+//	@Description	Turn off MFA for the current user and discard their recovery codes. Requires the password and a TOTP or recovery code. Admins and employees can only turn it off if they have a passkey. Wrong passwords and codes count as failed logins of the account.
+//	@Tags			mfa
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		MFAVerifyRequest	true	"Password and MFA code"
+//	@Success		200		{object}	object{message=string}
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		409		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/me/mfa [delete]
+func MFADisable(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := GetContextUserID(c)
+
+	var req MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := models.GetUser(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if !checkMFARequest(c, tx, &user, req) {
+		return
+	}
+
+	if user.Role.RequiresMFA() {
+		count, err := models.CountUserWebAuthnCredentials(tx, user.ID)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": errMFARequiredForRole})
+			return
+		}
+	}
+
+	if err := user.DisableMFA(tx); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	sendEmail(user.Email, "mfa-disabled", map[string]interface{}{
+		"Subject":  "Two-factor authentication disabled",
+		"UserName": user.FirstName,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled successfully"})
+}
+
+// MFARegenerateRecoveryCodes
+//
+//	@Id				MFARegenerateRecoveryCodes
+//	@Summary		Regenerate recovery codes
+//	@Description	Replace all recovery codes of the current user with new ones. Requires the password and a TOTP or recovery code. Wrong passwords and codes count as failed logins of the account.
+//	@Tags			mfa
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		MFAVerifyRequest	true	"Password and MFA code"
+//	@Success		200		{object}	RecoveryCodesResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/me/mfa/recovery-codes [post]
+func MFARegenerateRecoveryCodes(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := GetContextUserID(c)
+
+	var req MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := models.GetUser(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if !checkMFARequest(c, tx, &user, req) {
+		return
+	}
+
+	codes, err := issueRecoveryCodes(tx, user.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -0,0 +1,1194 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// testingEnableMFA turns on TOTP for the user and returns its secret and recovery codes
+func testingEnableMFA(t *testing.T, db *gorm.DB, userID uuid.UUID) (string, []string) {
+	user, err := models.GetUser(db, userID)
+	require.NoError(t, err)
+
+	key, err := auth.GenerateTOTPKey(user.Email)
+	require.NoError(t, err)
+
+	err = user.StartTOTPEnrollment(db, key.Secret())
+	require.NoError(t, err)
+	err = user.EnableTOTP(db, 0)
+	require.NoError(t, err)
+
+	codes, err := issueRecoveryCodes(db, userID)
+	require.NoError(t, err)
+
+	return key.Secret(), codes
+}
+
+// testingTOTPCode returns the current code of the secret
+func testingTOTPCode(t *testing.T, secret string) string {
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	return code
+}
+
+// testingMFAChallenge logs in as the customer and returns the MFA token
+func testingMFAChallenge(t *testing.T, r http.Handler) string {
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: "customer123",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var challenge MFAChallengeResponse
+	err := json.Unmarshal(w.Body.Bytes(), &challenge)
+	require.NoError(t, err)
+	require.True(t, challenge.MFARequired)
+
+	return challenge.MFAToken
+}
+
+func TestLoginRequiresMFA(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	testingEnableMFA(t, db, customerID)
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: "customer123",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ignoreResp := xtesting.ValuesCheckers{
+		"mfa_token": xtesting.ValueNotEqual(""),
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	xtesting.AssertGoldenJSON(t, w, ignoreResp)
+}
+
+func TestLoginMFA(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	accessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name     string
+		code     func(t *testing.T, secret string, recoveryCodes []string) string
+		mfaToken string
+		status   int
+	}{
+		{
+			name: "ok-totp",
+			code: func(t *testing.T, secret string, recoveryCodes []string) string {
+				return testingTOTPCode(t, secret)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "ok-recovery-code",
+			code: func(t *testing.T, secret string, recoveryCodes []string) string {
+				return recoveryCodes[0]
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "wrong-code",
+			code: func(t *testing.T, secret string, recoveryCodes []string) string {
+				return "000000"
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "invalid-mfa-token",
+			code: func(t *testing.T, secret string, recoveryCodes []string) string {
+				return testingTOTPCode(t, secret)
+			},
+			mfaToken: "invalid",
+			status:   http.StatusUnauthorized,
+		},
+		{
+			name: "access-token-as-mfa-token",
+			code: func(t *testing.T, secret string, recoveryCodes []string) string {
+				return testingTOTPCode(t, secret)
+			},
+			mfaToken: accessToken,
+			status:   http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			secret, recoveryCodes := testingEnableMFA(t, db, customerID)
+
+			mfaToken := testingMFAChallenge(t, r)
+			if testCase.mfaToken != "" {
+				mfaToken = testCase.mfaToken
+			}
+
+			targetURL := "/api/v1/auth/login/mfa"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, LoginMFARequest{
+				MFAToken: mfaToken,
+				Code:     testCase.code(t, secret, recoveryCodes),
+			})
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"access_token":  xtesting.ValueNotEqual(""),
+				"refresh_token": xtesting.ValueNotEqual(""),
+				"id_token":      xtesting.ValueNotEqual(""),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			if testCase.status == http.StatusOK {
+				xtesting.AssertGoldenJSON(t, w, ignoreResp)
+			} else {
+				xtesting.AssertGoldenJSON(t, w)
+			}
+		})
+	}
+}
+
+func TestLoginMFACodesAreSingleUse(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	// Wrong codes make the account wait before the next login
+	t.Setenv("LOGIN_BACKOFF_BASE", "1ms")
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	secret, recoveryCodes := testingEnableMFA(t, db, customerID)
+
+	loginMFA := func(code string) int {
+		time.Sleep(10 * time.Millisecond)
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa", http.MethodPost, LoginMFARequest{
+			MFAToken: testingMFAChallenge(t, r),
+			Code:     code,
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	code := testingTOTPCode(t, secret)
+	assert.Equal(t, http.StatusOK, loginMFA(code))
+	assert.Equal(t, http.StatusUnauthorized, loginMFA(code))
+
+	assert.Equal(t, http.StatusOK, loginMFA(recoveryCodes[1]))
+	assert.Equal(t, http.StatusUnauthorized, loginMFA(recoveryCodes[1]))
+
+	remaining, err := models.CountUnusedRecoveryCodes(db, customerID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(auth.RecoveryCodeCount-1), remaining)
+}
+
+// unsyncedRevocationBackend stands in for the revoked tokens as seen by a replica
+// that hasn't synced any revocation yet
+type unsyncedRevocationBackend struct{}
+
+func (unsyncedRevocationBackend) RevokedTokensSince(since time.Time) (map[string]time.Time, error) {
+	return map[string]time.Time{}, nil
+}
+
+func TestLoginMFATokenIsSingleUse(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	secret, recoveryCodes := testingEnableMFA(t, db, customerID)
+	mfaToken := testingMFAChallenge(t, r)
+
+	loginMFA := func(code string) *httptest.ResponseRecorder {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa", http.MethodPost, LoginMFARequest{
+			MFAToken: mfaToken,
+			Code:     code,
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, loginMFA(testingTOTPCode(t, secret)).Code)
+
+	// Another valid code doesn't get the same MFA token a second session
+	w := loginMFA(recoveryCodes[0])
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+
+	// Nor does it on a replica that hasn't synced the revocation yet
+	auth.SetRevocationList(auth.NewRevocationList(unsyncedRevocationBackend{}))
+	w = loginMFA(recoveryCodes[1])
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestLoginMFALockout(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1ms")
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	testingEnableMFA(t, db, customerID)
+
+	// Logging in with the password again doesn't forget the wrong codes
+	for range 3 {
+		time.Sleep(10 * time.Millisecond)
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa", http.MethodPost, LoginMFARequest{
+			MFAToken: testingMFAChallenge(t, r),
+			Code:     "000000",
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	user, err := models.GetUser(db, customerID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, user.FailedLoginAttempts)
+	assert.True(t, user.IsLocked())
+
+	// The locked account can't even get a new MFA token
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: "customer123",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMFAStatus(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name    string
+		token   string
+		enabled bool
+		status  int
+	}{
+		{
+			name:    "ok-enabled",
+			token:   validToken,
+			enabled: true,
+			status:  http.StatusOK,
+		},
+		{
+			name:   "ok-disabled",
+			token:  validToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "no-token",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			if testCase.enabled {
+				testingEnableMFA(t, db, customerID)
+			}
+
+			targetURL := "/api/v1/auth/me/mfa"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodGet, nil)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+		})
+	}
+}
+
+func TestTOTPEnroll(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name    string
+		token   string
+		body    TOTPEnrollRequest
+		enabled bool
+		status  int
+	}{
+		{
+			name:   "ok",
+			token:  validToken,
+			body:   TOTPEnrollRequest{Password: "customer123"},
+			status: http.StatusOK,
+		},
+		{
+			name:   "wrong-password",
+			token:  validToken,
+			body:   TOTPEnrollRequest{Password: "wrongpassword"},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:    "already-enabled",
+			token:   validToken,
+			body:    TOTPEnrollRequest{Password: "customer123"},
+			enabled: true,
+			status:  http.StatusConflict,
+		},
+		{
+			name:   "no-token",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			if testCase.enabled {
+				testingEnableMFA(t, db, customerID)
+			}
+
+			targetURL := "/api/v1/auth/me/mfa/totp"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"secret":      xtesting.ValueRegexp(`^[A-Z2-7]{32}$`),
+				"otpauth_uri": xtesting.ValueRegexp(`^otpauth://totp/CineCore:customer@example.com\?`),
+				"qr_code":     xtesting.ValueRegexp(`^data:image/png;base64,`),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+
+			if testCase.status == http.StatusOK {
+				user, err := models.GetUser(db, customerID)
+				require.NoError(t, err)
+				assert.NotNil(t, user.TOTPSecret)
+				assert.False(t, user.HasMFA())
+			}
+		})
+	}
+}
+
+func TestTOTPEnrollLockout(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1ms")
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	enroll := func(password string) *httptest.ResponseRecorder {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/mfa/totp", http.MethodPost, TOTPEnrollRequest{Password: password})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", validToken))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// A stolen access token can't be used to guess the password
+	for range 3 {
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, http.StatusUnauthorized, enroll("wrongpassword").Code)
+	}
+
+	user, err := models.GetUser(db, customerID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, user.FailedLoginAttempts)
+	assert.True(t, user.IsLocked())
+
+	w := enroll("customer123")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+
+	user, err = models.GetUser(db, customerID)
+	require.NoError(t, err)
+	assert.Nil(t, user.TOTPSecret)
+}
+
+func TestTOTPEnrollEncryptsSecret(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "a-long-enough-encryption-key-for-signing-keys")
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/mfa/totp", http.MethodPost, TOTPEnrollRequest{Password: "customer123"})
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", validToken))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var enrollment TOTPEnrollmentResponse
+	err = json.Unmarshal(w.Body.Bytes(), &enrollment)
+	require.NoError(t, err)
+
+	// Reading the database doesn't reveal the secret
+	user, err := models.GetUser(db, customerID)
+	require.NoError(t, err)
+	require.NotNil(t, user.TOTPSecret)
+	assert.True(t, user.TOTPSecretEncrypted)
+	assert.NotContains(t, *user.TOTPSecret, enrollment.Secret)
+
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/me/mfa/totp/confirm", http.MethodPost, TOTPConfirmRequest{
+		Code: testingTOTPCode(t, enrollment.Secret),
+	})
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", validToken))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestTOTPConfirm(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name    string
+		started bool
+		enabled bool
+		code    string
+		status  int
+	}{
+		{
+			name:    "ok",
+			started: true,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "invalid-code",
+			started: true,
+			code:    "000000",
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:   "not-started",
+			code:   "000000",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:    "already-enabled",
+			enabled: true,
+			status:  http.StatusConflict,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			var secret string
+			if testCase.enabled {
+				secret, _ = testingEnableMFA(t, db, customerID)
+			}
+			if testCase.started {
+				key, err := auth.GenerateTOTPKey("customer@example.com")
+				require.NoError(t, err)
+				user, err := models.GetUser(db, customerID)
+				require.NoError(t, err)
+				err = user.StartTOTPEnrollment(db, key.Secret())
+				require.NoError(t, err)
+				secret = key.Secret()
+			}
+
+			code := testCase.code
+			if code == "" {
+				code = testingTOTPCode(t, secret)
+			}
+
+			targetURL := "/api/v1/auth/me/mfa/totp/confirm"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, TOTPConfirmRequest{Code: code})
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", validToken))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{}
+			for i := range auth.RecoveryCodeCount {
+				ignoreResp[fmt.Sprintf("recovery_codes.[%d]", i)] = xtesting.ValueRegexp(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+
+			if testCase.status == http.StatusOK {
+				user, err := models.GetUser(db, customerID)
+				require.NoError(t, err)
+				assert.True(t, user.HasMFA())
+			}
+		})
+	}
+}
+
+func TestMFADisable(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name     string
+		disabled bool
+		password string
+		code     string
+		status   int
+	}{
+		{
+			name:     "ok",
+			password: "customer123",
+			status:   http.StatusOK,
+		},
+		{
+			name:     "ok-recovery-code",
+			password: "customer123",
+			code:     "recovery",
+			status:   http.StatusOK,
+		},
+		{
+			name:     "wrong-password",
+			password: "wrongpassword",
+			status:   http.StatusUnauthorized,
+		},
+		{
+			name:     "invalid-code",
+			password: "customer123",
+			code:     "000000",
+			status:   http.StatusUnauthorized,
+		},
+		{
+			name:     "not-enabled",
+			disabled: true,
+			password: "customer123",
+			code:     "000000",
+			status:   http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			code := testCase.code
+			if !testCase.disabled {
+				secret, recoveryCodes := testingEnableMFA(t, db, customerID)
+				switch code {
+				case "":
+					code = testingTOTPCode(t, secret)
+				case "recovery":
+					code = recoveryCodes[0]
+				}
+			}
+
+			targetURL := "/api/v1/auth/me/mfa"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodDelete, MFAVerifyRequest{
+				Password: testCase.password,
+				Code:     code,
+			})
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", validToken))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+
+			if testCase.status == http.StatusOK {
+				user, err := models.GetUser(db, customerID)
+				require.NoError(t, err)
+				assert.False(t, user.HasMFA())
+				assert.Nil(t, user.TOTPSecret)
+
+				remaining, err := models.CountUnusedRecoveryCodes(db, customerID)
+				require.NoError(t, err)
+				assert.Zero(t, remaining)
+			}
+		})
+	}
+}
+
+func TestMFADisableLockout(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1ms")
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	secret, _ := testingEnableMFA(t, db, customerID)
+
+	send := func(method, targetURL, code string) *httptest.ResponseRecorder {
+		req := xtesting.NewTestingRequest(t, targetURL, method, MFAVerifyRequest{
+			Password: "customer123",
+			Code:     code,
+		})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", validToken))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// A stolen access token can't be used to guess the code and turn MFA off
+	for range 3 {
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, http.StatusUnauthorized, send(http.MethodDelete, "/api/v1/auth/me/mfa", "000000").Code)
+	}
+
+	user, err := models.GetUser(db, customerID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, user.FailedLoginAttempts)
+	assert.True(t, user.IsLocked())
+
+	w := send(http.MethodPost, "/api/v1/auth/me/mfa/recovery-codes", testingTOTPCode(t, secret))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+
+	user, err = models.GetUser(db, customerID)
+	require.NoError(t, err)
+	assert.True(t, user.HasMFA())
+}
+
+func TestMFARegenerateRecoveryCodes(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	secret, oldCodes := testingEnableMFA(t, db, customerID)
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/mfa/recovery-codes", http.MethodPost, MFAVerifyRequest{
+		Password: "customer123",
+		Code:     testingTOTPCode(t, secret),
+	})
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", validToken))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response RecoveryCodesResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Len(t, response.RecoveryCodes, auth.RecoveryCodeCount)
+
+	// The old codes stop working
+	used, err := models.UseRecoveryCode(db, customerID, auth.HashRecoveryCode(oldCodes[0]))
+	require.NoError(t, err)
+	assert.False(t, used)
+
+	used, err = models.UseRecoveryCode(db, customerID, auth.HashRecoveryCode(response.RecoveryCodes[0]))
+	require.NoError(t, err)
+	assert.True(t, used)
+}
+
+// testingMFAEnrollmentToken logs in as the employee, who has to set up MFA, and
+// returns the MFA token
+func testingMFAEnrollmentToken(t *testing.T, r http.Handler) string {
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "employee@example.com",
+		Password: "employee123",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var challenge MFAChallengeResponse
+	err := json.Unmarshal(w.Body.Bytes(), &challenge)
+	require.NoError(t, err)
+	require.True(t, challenge.MFAEnrollmentRequired)
+
+	return challenge.MFAToken
+}
+
+func TestMFARequiredByRole(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	employeeID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
+	adminPasswordToken, _ := auth.GenerateToken(adminID, "admin@example.com", auth.WithAMR(auth.AMRPassword))
+	employeeToken, _ := auth.GenerateToken(employeeID, "employee@example.com")
+	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{
+			name:   "ok-admin-with-mfa",
+			token:  adminToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "ok-customer-without-mfa",
+			token:  customerToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "admin-without-mfa",
+			token:  adminPasswordToken,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "employee-without-mfa",
+			token:  employeeToken,
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			targetURL := "/api/v1/auth/me/mfa"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodGet, nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+		})
+	}
+}
+
+func TestRefreshTokenKeepsMFA(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	tests := []struct {
+		name   string
+		opts   []auth.TokenOption
+		status int
+	}{
+		{
+			name:   "ok",
+			opts:   []auth.TokenOption{TestingMFALogin},
+			status: http.StatusOK,
+		},
+		{
+			name:   "admin-without-mfa",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			refreshToken, _ := TestingRefreshToken(t, db, adminID, "admin@example.com", testCase.opts...)
+
+			targetURL := "/api/v1/auth/refresh"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, map[string]string{
+				"refresh_token": refreshToken,
+			})
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"access_token":  xtesting.ValueNotEqual(""),
+				"refresh_token": xtesting.ValueNotEqual(""),
+				"id_token":      xtesting.ValueNotEqual(""),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+
+			if testCase.status == http.StatusOK {
+				var tokens TokenResponse
+				err = json.Unmarshal(w.Body.Bytes(), &tokens)
+				require.NoError(t, err)
+
+				claims, err := auth.ValidateToken(tokens.AccessToken, auth.TokenTypeAccess)
+				require.NoError(t, err)
+				assert.True(t, claims.HasMFA())
+			}
+		})
+	}
+}
+
+func TestLoginMFAEnrollment(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	mfaToken := testingMFAEnrollmentToken(t, r)
+
+	// The token can't be used to log in without setting up MFA
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa", http.MethodPost, LoginMFARequest{
+		MFAToken: mfaToken,
+		Code:     "000000",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa/totp", http.MethodPost, LoginTOTPEnrollRequest{
+		MFAToken: mfaToken,
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var enrollment TOTPEnrollmentResponse
+	err = json.Unmarshal(w.Body.Bytes(), &enrollment)
+	require.NoError(t, err)
+
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa/totp/confirm", http.MethodPost, LoginTOTPConfirmRequest{
+		MFAToken: mfaToken,
+		Code:     testingTOTPCode(t, enrollment.Secret),
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response MFAEnrollmentResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Len(t, response.RecoveryCodes, auth.RecoveryCodeCount)
+
+	claims, err := auth.ValidateToken(response.AccessToken, auth.TokenTypeAccess)
+	require.NoError(t, err)
+	assert.Equal(t, []string{auth.AMRPassword, auth.AMROTP, auth.AMRMultiFactor}, claims.AMR)
+
+	// Once MFA is set up, the token can't be used to replace it
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa/totp", http.MethodPost, LoginTOTPEnrollRequest{
+		MFAToken: mfaToken,
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestLoginTOTPEnroll(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+
+	tests := []struct {
+		name     string
+		mfaToken func(t *testing.T) string
+		status   int
+	}{
+		{
+			name:     "ok",
+			mfaToken: func(t *testing.T) string { return testingMFAEnrollmentToken(t, r) },
+			status:   http.StatusOK,
+		},
+		{
+			name: "user-with-mfa",
+			mfaToken: func(t *testing.T) string {
+				testingEnableMFA(t, db, customerID)
+				return testingMFAChallenge(t, r)
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:     "invalid-token",
+			mfaToken: func(t *testing.T) string { return "invalid" },
+			status:   http.StatusUnauthorized,
+		},
+		{
+			name:     "validation-error",
+			mfaToken: func(t *testing.T) string { return "" },
+			status:   http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			targetURL := "/api/v1/auth/login/mfa/totp"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, LoginTOTPEnrollRequest{
+				MFAToken: testCase.mfaToken(t),
+			})
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"secret":      xtesting.ValueRegexp(`^[A-Z2-7]{32}$`),
+				"otpauth_uri": xtesting.ValueRegexp(`^otpauth://totp/CineCore:employee@example.com\?`),
+				"qr_code":     xtesting.ValueRegexp(`^data:image/png;base64,`),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+		})
+	}
+}
+
+func TestLoginTOTPConfirm(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	employeeID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+
+	tests := []struct {
+		name       string
+		code       string
+		notStarted bool
+		mfaToken   string
+		status     int
+	}{
+		{
+			name:   "ok",
+			status: http.StatusOK,
+		},
+		{
+			name:   "invalid-code",
+			code:   "000000",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:       "not-started",
+			code:       "000000",
+			notStarted: true,
+			status:     http.StatusBadRequest,
+		},
+		{
+			name:     "invalid-token",
+			code:     "000000",
+			mfaToken: "invalid",
+			status:   http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			mfaToken := testingMFAEnrollmentToken(t, r)
+
+			code := testCase.code
+			if !testCase.notStarted {
+				req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa/totp", http.MethodPost, LoginTOTPEnrollRequest{
+					MFAToken: mfaToken,
+				})
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				require.Equal(t, http.StatusOK, w.Code)
+
+				var enrollment TOTPEnrollmentResponse
+				err = json.Unmarshal(w.Body.Bytes(), &enrollment)
+				require.NoError(t, err)
+
+				if code == "" {
+					code = testingTOTPCode(t, enrollment.Secret)
+				}
+			}
+
+			if testCase.mfaToken != "" {
+				mfaToken = testCase.mfaToken
+			}
+
+			targetURL := "/api/v1/auth/login/mfa/totp/confirm"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, LoginTOTPConfirmRequest{
+				MFAToken: mfaToken,
+				Code:     code,
+			})
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"access_token":  xtesting.ValueNotEqual(""),
+				"refresh_token": xtesting.ValueNotEqual(""),
+				"id_token":      xtesting.ValueNotEqual(""),
+			}
+			for i := range auth.RecoveryCodeCount {
+				ignoreResp[fmt.Sprintf("recovery_codes.[%d]", i)] = xtesting.ValueRegexp(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+
+			user, err := models.GetUser(db, employeeID)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.status == http.StatusOK, user.HasMFA())
+		})
+	}
+}
+
+func TestLoginTOTPConfirmLockout(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1ms")
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	mfaToken := testingMFAEnrollmentToken(t, r)
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa/totp", http.MethodPost, LoginTOTPEnrollRequest{
+		MFAToken: mfaToken,
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var enrollment TOTPEnrollmentResponse
+	err = json.Unmarshal(w.Body.Bytes(), &enrollment)
+	require.NoError(t, err)
+
+	confirm := func(code string) *httptest.ResponseRecorder {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa/totp/confirm", http.MethodPost, LoginTOTPConfirmRequest{
+			MFAToken: mfaToken,
+			Code:     code,
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Wrong codes count as failed logins, so the code can't be guessed with one MFA token
+	for range 3 {
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, http.StatusBadRequest, confirm("000000").Code)
+	}
+
+	employeeID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	user, err := models.GetUser(db, employeeID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, user.FailedLoginAttempts)
+	assert.True(t, user.IsLocked())
+
+	w = confirm(testingTOTPCode(t, enrollment.Secret))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestMFADisableRequiredByRole(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+	testingClearPasskeys(t, db)
+
+	employeeID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	employeeToken, _ := auth.GenerateToken(employeeID, "employee@example.com", TestingMFALogin)
+	secret, _ := testingEnableMFA(t, db, employeeID)
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/mfa", http.MethodDelete, MFAVerifyRequest{
+		Password: "employee123",
+		Code:     testingTOTPCode(t, secret),
+	})
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", employeeToken))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+
+	user, err := models.GetUser(db, employeeID)
+	require.NoError(t, err)
+	assert.True(t, user.HasMFA())
+}
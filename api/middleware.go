@@ -1,8 +1,11 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/PRPO-skupina-02/auth/auth"
 	"github.com/PRPO-skupina-02/auth/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -36,6 +39,47 @@ func RequireAdmin() gin.HandlerFunc {
 	return RequireRole(models.RoleAdmin)
 }
 
+// errRecentAuthRequired is the error for tokens issued too long after the user
+// authenticated for a sensitive operation
+const errRecentAuthRequired = "Recent authentication required"
+
+// hasRecentAuth reports whether the user logged in or reauthenticated within maxAge
+func hasRecentAuth(claims *auth.Claims, maxAge time.Duration) bool {
+	authTime := claims.AuthenticatedAt()
+	return !authTime.IsZero() && time.Since(authTime) <= maxAge
+}
+
+// recentAuthRequired asks the client to reauthenticate at /reauthenticate, with
+// the challenge of RFC 9470
+func recentAuthRequired(c *gin.Context, maxAge time.Duration) {
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_user_authentication", max_age=%d`, int(maxAge.Seconds())))
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": errRecentAuthRequired})
+}
+
+// RequireRecentAuth middleware checks that the user logged in or reauthenticated
+// within maxAge, so a stolen or long-lived token isn't enough for sensitive operations
+func RequireRecentAuth(maxAge time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasRecentAuth(GetContextClaims(c), maxAge) {
+			recentAuthRequired(c, maxAge)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// errMFARequired is the error for tokens without the second factor the role of
+// the user requires
+const errMFARequired = "Multi-factor authentication required"
+
+// satisfiesMFAPolicy reports whether the token was issued after a login with a
+// second factor, if the role of the user requires one. Tokens from before MFA was
+// required or from before a role change have to be replaced by logging in again.
+func satisfiesMFAPolicy(claims *auth.Claims, user models.User) bool {
+	return !user.Role.RequiresMFA() || claims.HasMFA()
+}
+
 // GetContextUserID retrieves the user ID from the context
 func GetContextUserID(c *gin.Context) uuid.UUID {
 	return c.MustGet("user_id").(uuid.UUID)
@@ -45,3 +89,8 @@ func GetContextUserID(c *gin.Context) uuid.UUID {
 func GetContextUserRole(c *gin.Context) models.UserRole {
 	return c.MustGet("user_role").(models.UserRole)
 }
+
+// GetContextClaims retrieves the claims of the access token used to authenticate the request
+func GetContextClaims(c *gin.Context) *auth.Claims {
+	return c.MustGet("token_claims").(*auth.Claims)
+}
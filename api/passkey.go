@@ -0,0 +1,670 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/middleware"
+	"github.com/PRPO-skupina-02/common/request"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const defaultPasskeyName = "Passkey"
+
+type PasskeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func newPasskeyResponse(credential models.WebAuthnCredential) PasskeyResponse {
+	return PasskeyResponse{
+		ID:         credential.ID,
+		Name:       credential.Name,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+}
+
+// PasskeyOptionsResponse starts a passkey ceremony. The options are passed to
+// navigator.credentials.create or navigator.credentials.get, and the session
+// token is sent back together with their result.
+type PasskeyOptionsResponse struct {
+	SessionToken string `json:"session_token"`
+	Options      any    `json:"options" swaggertype:"object"`
+}
+
+// startPasskeyCeremony stores the session of a started ceremony and returns the
+// token the client finishes it with
+func startPasskeyCeremony(tx *gorm.DB, ceremony string, userID *uuid.UUID, session *webauthn.SessionData, options any) (PasskeyOptionsResponse, error) {
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return PasskeyOptionsResponse{}, err
+	}
+
+	webAuthnSession := models.WebAuthnSession{
+		UserID:    userID,
+		Ceremony:  ceremony,
+		TokenHash: auth.HashToken(token),
+		Data:      *session,
+		ExpiresAt: session.Expires,
+	}
+	if err := webAuthnSession.Create(tx); err != nil {
+		return PasskeyOptionsResponse{}, err
+	}
+
+	return PasskeyOptionsResponse{SessionToken: token, Options: options}, nil
+}
+
+// takePasskeySession returns the session data of the token, reporting false if
+// the token doesn't belong to an unexpired ceremony of the user. The session is
+// used up either way.
+func takePasskeySession(tx *gorm.DB, token string, ceremony string, userID *uuid.UUID) (webauthn.SessionData, bool, error) {
+	session, err := models.TakeWebAuthnSession(tx, auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return webauthn.SessionData{}, false, nil
+		}
+		return webauthn.SessionData{}, false, err
+	}
+
+	if !session.IsValid(ceremony, userID) {
+		return webauthn.SessionData{}, false, nil
+	}
+	return session.Data, true, nil
+}
+
+// recordPasskeyUse stores the authenticator state after a successful assertion.
+// It refuses the passkey if its sign count went backwards, which suggests the
+// authenticator was cloned.
+func recordPasskeyUse(tx *gorm.DB, user models.WebAuthnUser, credential *webauthn.Credential) (bool, error) {
+	if credential.Authenticator.CloneWarning {
+		return false, nil
+	}
+
+	stored := user.FindCredential(credential.ID)
+	if stored == nil {
+		return false, nil
+	}
+
+	if err := stored.RecordUse(tx, *credential); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// requiresSecondFactor reports whether a password login of the user has to be
+// completed with a second factor. Besides TOTP, a passkey counts as one for the
+// roles that require MFA.
+func requiresSecondFactor(tx *gorm.DB, user models.User) (bool, error) {
+	if user.HasMFA() {
+		return true, nil
+	}
+	if !user.Role.RequiresMFA() {
+		return false, nil
+	}
+
+	count, err := models.CountUserWebAuthnCredentials(tx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// verifyMFAPasskey accepts the result of a passkey assertion started with the MFA
+// token of the user
+func verifyMFAPasskey(tx *gorm.DB, user models.User, sessionToken string, response json.RawMessage) (bool, error) {
+	session, ok, err := takePasskeySession(tx, sessionToken, models.WebAuthnCeremonyMFA, &user.ID)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return false, nil
+	}
+
+	webAuthn, err := auth.NewWebAuthn()
+	if err != nil {
+		return false, err
+	}
+
+	webAuthnUser, err := models.GetWebAuthnUser(tx, user)
+	if err != nil {
+		return false, err
+	}
+
+	credential, err := webAuthn.ValidateLogin(webAuthnUser, session, parsed)
+	if err != nil {
+		return false, nil
+	}
+
+	return recordPasskeyUse(tx, webAuthnUser, credential)
+}
+
+// PasskeysList
+//
+//	@Id				PasskeysList
+//	@Summary		List passkeys
+//	@Description	List the passkeys of the current user
+//	@Tags			passkeys
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		PasskeyResponse
+//	@Failure		401	{object}	middleware.HttpError
+//	@Failure		500	{object}	middleware.HttpError
+//	@Router			/me/passkeys [get]
+func PasskeysList(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := GetContextUserID(c)
+
+	credentials, err := models.GetUserWebAuthnCredentials(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := make([]PasskeyResponse, len(credentials))
+	for i, credential := range credentials {
+		response[i] = newPasskeyResponse(credential)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+type PasskeyRegisterOptionsRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// PasskeyRegisterOptions
+//
+//	@Id				PasskeyRegisterOptions
+//	@Summary		Start passkey registration
+//	@Description	Start registering a passkey for the current user. Pass the options to navigator.credentials.create and send the result to POST /me/passkeys. Wrong passwords count as failed logins of the account.
+//	@Tags			passkeys
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		PasskeyRegisterOptionsRequest	true	"Current password"
+//	@Success		200		{object}	PasskeyOptionsResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/me/passkeys/options [post]
+func PasskeyRegisterOptions(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := GetContextUserID(c)
+
+	var req PasskeyRegisterOptionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := models.GetUser(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if !checkUserPassword(c, tx, &user, req.Password) {
+		return
+	}
+	if !resetFailedLogins(c, tx, &user) {
+		return
+	}
+
+	webAuthn, err := auth.NewWebAuthn()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	webAuthnUser, err := models.GetWebAuthnUser(tx, user)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// Passkeys are discoverable and verify the user, so they can replace the
+	// password on their own
+	creation, session, err := webAuthn.BeginRegistration(webAuthnUser,
+		webauthn.WithExclusions(webauthn.Credentials(webAuthnUser.WebAuthnCredentials()).CredentialDescriptors()),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		}),
+	)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response, err := startPasskeyCeremony(tx, models.WebAuthnCeremonyRegistration, &user.ID, session, creation)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+type PasskeyRegisterRequest struct {
+	SessionToken string `json:"session_token" binding:"required"`
+	Name         string `json:"name" binding:"omitempty,max=64"`
+	// Credential is the result of navigator.credentials.create
+	Credential json.RawMessage `json:"credential" binding:"required" swaggertype:"object"`
+}
+
+// PasskeyRegister
+//
+//	@Id				PasskeyRegister
+//	@Summary		Finish passkey registration
+//	@Description	Store the passkey created with the options of POST /me/passkeys/options
+//	@Tags			passkeys
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		PasskeyRegisterRequest	true	"Session token and created credential"
+//	@Success		201		{object}	PasskeyResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		409		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Router			/me/passkeys [post]
+func PasskeyRegister(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := GetContextUserID(c)
+
+	var req PasskeyRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	session, ok, err := takePasskeySession(tx, req.SessionToken, models.WebAuthnCeremonyRegistration, &userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired passkey session"})
+		return
+	}
+
+	user, err := models.GetUser(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	webAuthn, err := auth.NewWebAuthn()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	webAuthnUser, err := models.GetWebAuthnUser(tx, user)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid passkey"})
+		return
+	}
+
+	credential, err := webAuthn.CreateCredential(webAuthnUser, session, parsed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid passkey"})
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = defaultPasskeyName
+	}
+
+	passkey := models.WebAuthnCredential{
+		UserID:       user.ID,
+		CredentialID: credential.ID,
+		Name:         name,
+		Credential:   *credential,
+	}
+	if err := passkey.Create(tx); err != nil {
+		if models.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Passkey is already registered"})
+			return
+		}
+		_ = c.Error(err)
+		return
+	}
+
+	sendEmail(user.Email, "passkey-added", map[string]interface{}{
+		"Subject":     "Passkey added",
+		"UserName":    user.FirstName,
+		"PasskeyName": passkey.Name,
+	})
+
+	c.JSON(http.StatusCreated, newPasskeyResponse(passkey))
+}
+
+// PasskeyDelete
+//
+//	@Id				PasskeyDelete
+//	@Summary		Delete passkey
+//	@Description	Remove a passkey of the current user. Admins and employees without TOTP can't remove their last passkey.
+//	@Tags			passkeys
+//	@Security		BearerAuth
+//	@Param			passkeyID	path	string	true	"Passkey ID"	Format(uuid)
+//	@Success		204
+//	@Failure		400	{object}	middleware.HttpError
+//	@Failure		401	{object}	middleware.HttpError
+//	@Failure		404	{object}	middleware.HttpError
+//	@Failure		409	{object}	middleware.HttpError
+//	@Failure		500	{object}	middleware.HttpError
+//	@Router			/me/passkeys/{passkeyID} [delete]
+func PasskeyDelete(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := GetContextUserID(c)
+
+	passkeyID, err := request.GetUUIDParam(c, "passkeyID")
+	if err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := models.GetUser(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	passkey, err := models.GetUserWebAuthnCredential(tx, userID, passkeyID)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+
+	if user.Role.RequiresMFA() && !user.HasMFA() {
+		count, err := models.CountUserWebAuthnCredentials(tx, user.ID)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		if count == 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": errMFARequiredForRole})
+			return
+		}
+	}
+
+	if err := passkey.Delete(tx); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	sendEmail(user.Email, "passkey-removed", map[string]interface{}{
+		"Subject":     "Passkey removed",
+		"UserName":    user.FirstName,
+		"PasskeyName": passkey.Name,
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// LoginPasskeyOptions
+//
+//	@Id				LoginPasskeyOptions
+//	@Summary		Start passkey login
+//	@Description	Start a passwordless login. Pass the options to navigator.credentials.get and send the result to POST /login/passkey.
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200	{object}	PasskeyOptionsResponse
+//	@Failure		429	{object}	middleware.HttpError
+//	@Failure		500	{object}	middleware.HttpError
+//	@Failure		503	{object}	middleware.HttpError
+//	@Router			/login/passkey/options [post]
+func LoginPasskeyOptions(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	webAuthn, err := auth.NewWebAuthn()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	assertion, session, err := webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response, err := startPasskeyCeremony(tx, models.WebAuthnCeremonyLogin, nil, session, assertion)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+type LoginPasskeyRequest struct {
+	SessionToken string `json:"session_token" binding:"required"`
+	// Credential is the result of navigator.credentials.get
+	Credential json.RawMessage `json:"credential" binding:"required" swaggertype:"object"`
+}
+
+// LoginPasskey
+//
+//	@Id				LoginPasskey
+//	@Summary		Log in with a passkey
+//	@Description	Exchange the passkey assertion started at POST /login/passkey/options for JWT tokens. The passkey verifies the user, so no password or MFA code is needed.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		LoginPasskeyRequest	true	"Session token and passkey assertion"
+//	@Success		200		{object}	TokenResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		403		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/login/passkey [post]
+func LoginPasskey(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req LoginPasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	ipAddress := c.ClientIP()
+	ipFailure, err := models.GetIPLoginFailure(tx, ipAddress)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if ipFailure.IsBlocked() {
+		tooManyLoginAttempts(c, *ipFailure.BlockedUntil)
+		return
+	}
+
+	// Every failure gets the same response, and is counted against the address
+	// only, as the account may not be known
+	invalidPasskey := func() {
+		if err := recordIPLoginFailure(tx, ipAddress); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid passkey"})
+	}
+
+	session, ok, err := takePasskeySession(tx, req.SessionToken, models.WebAuthnCeremonyLogin, nil)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !ok {
+		invalidPasskey()
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		invalidPasskey()
+		return
+	}
+
+	webAuthn, err := auth.NewWebAuthn()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var webAuthnUser models.WebAuthnUser
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		user, err := models.GetUser(tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		webAuthnUser, err = models.GetWebAuthnUser(tx, user)
+		if err != nil {
+			return nil, err
+		}
+		return webAuthnUser, nil
+	}
+
+	_, credential, err := webAuthn.ValidatePasskeyLogin(findUser, session, parsed)
+	if err != nil {
+		invalidPasskey()
+		return
+	}
+
+	user := webAuthnUser.User
+	if !user.Active || user.IsLocked() {
+		invalidPasskey()
+		return
+	}
+
+	valid, err := recordPasskeyUse(tx, webAuthnUser, credential)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !valid {
+		invalidPasskey()
+		return
+	}
+
+	if user.FailedLoginAttempts > 0 {
+		if err := user.ResetFailedLogins(tx); err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+
+	if auth.RequireEmailVerification() && !user.IsEmailVerified() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email address is not verified"})
+		return
+	}
+
+	// The passkey verified the user too, so it is a second factor by itself
+	tokens, err := issueTokens(c, tx, user, uuid.New(), nil, []string{auth.AMRHardwareKey, auth.AMRMultiFactor}, time.Now())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+type LoginMFAPasskeyOptionsRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// LoginMFAPasskeyOptions
+//
+//	@Id				LoginMFAPasskeyOptions
+//	@Summary		Start passkey MFA
+//	@Description	Start confirming a password login with a passkey instead of a TOTP code. Pass the options to navigator.credentials.get and send the result to POST /login/mfa together with the MFA token.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		LoginMFAPasskeyOptionsRequest	true	"MFA token"
+//	@Success		200		{object}	PasskeyOptionsResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/login/mfa/passkey/options [post]
+func LoginMFAPasskeyOptions(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req LoginMFAPasskeyOptionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, _, ok := getMFAChallengeUser(c, tx, req.MFAToken, false)
+	if !ok {
+		return
+	}
+
+	startMFAPasskeyCeremony(c, tx, user)
+}
+
+// startMFAPasskeyCeremony responds with the options of an assertion by one of the
+// passkeys of the user, confirming their password
+func startMFAPasskeyCeremony(c *gin.Context, tx *gorm.DB, user models.User) {
+	webAuthnUser, err := models.GetWebAuthnUser(tx, user)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if len(webAuthnUser.Credentials) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No passkeys are registered"})
+		return
+	}
+
+	webAuthn, err := auth.NewWebAuthn()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	assertion, session, err := webAuthn.BeginLogin(webAuthnUser)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response, err := startPasskeyCeremony(tx, models.WebAuthnCeremonyMFA, &user.ID, session, assertion)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,903 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const (
+	authenticatorFlagUserPresent  = 0x01
+	authenticatorFlagUserVerified = 0x04
+	authenticatorFlagAttestedData = 0x40
+)
+
+// testingPasskey is a software authenticator, which answers passkey ceremonies
+// like a browser with a platform authenticator would
+type testingPasskey struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+// testingPasskeyOptions are the parts of the ceremony options the authenticator
+// needs
+type testingPasskeyOptions struct {
+	SessionToken string `json:"session_token"`
+	Options      struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			User      struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	} `json:"options"`
+}
+
+func newTestingPasskey(t *testing.T) *testingPasskey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	credentialID := make([]byte, 16)
+	_, err = rand.Read(credentialID)
+	require.NoError(t, err)
+
+	return &testingPasskey{key: key, credentialID: credentialID}
+}
+
+func testingParsePasskeyOptions(t *testing.T, body []byte) testingPasskeyOptions {
+	var options testingPasskeyOptions
+	err := json.Unmarshal(body, &options)
+	require.NoError(t, err)
+	require.NotEmpty(t, options.SessionToken)
+	return options
+}
+
+func (p *testingPasskey) clientData(t *testing.T, ceremony string, challenge string) []byte {
+	clientData, err := json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      auth.GetWebAuthnOrigins()[0],
+		"crossOrigin": false,
+	})
+	require.NoError(t, err)
+	return clientData
+}
+
+func (p *testingPasskey) authenticatorData(t *testing.T, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(auth.GetWebAuthnRPID()))
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, p.signCount)
+
+	if flags&authenticatorFlagAttestedData != 0 {
+		publicKey, err := cbor.Marshal(map[int]any{
+			1:  2,  // EC2 key
+			3:  -7, // ES256
+			-1: 1,  // P-256
+			-2: p.key.PublicKey.X.FillBytes(make([]byte, 32)),
+			-3: p.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+		})
+		require.NoError(t, err)
+
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(p.credentialID)))
+		data = append(data, p.credentialID...)
+		data = append(data, publicKey...)
+	}
+	return data
+}
+
+// create answers registration options with a new credential
+func (p *testingPasskey) create(t *testing.T, options testingPasskeyOptions) json.RawMessage {
+	userHandle, err := base64.RawURLEncoding.DecodeString(options.Options.PublicKey.User.ID)
+	require.NoError(t, err)
+	p.userHandle = userHandle
+
+	attestationObject, err := cbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": p.authenticatorData(t, authenticatorFlagUserPresent|authenticatorFlagUserVerified|authenticatorFlagAttestedData),
+	})
+	require.NoError(t, err)
+
+	encode := base64.RawURLEncoding.EncodeToString
+	credential, err := json.Marshal(map[string]any{
+		"id":    encode(p.credentialID),
+		"rawId": encode(p.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encode(p.clientData(t, "webauthn.create", options.Options.PublicKey.Challenge)),
+			"attestationObject": encode(attestationObject),
+			"transports":        []string{"internal"},
+		},
+	})
+	require.NoError(t, err)
+	return credential
+}
+
+// get answers login options with an assertion signed by the credential
+func (p *testingPasskey) get(t *testing.T, options testingPasskeyOptions) json.RawMessage {
+	p.signCount++
+
+	clientData := p.clientData(t, "webauthn.get", options.Options.PublicKey.Challenge)
+	authenticatorData := p.authenticatorData(t, authenticatorFlagUserPresent|authenticatorFlagUserVerified)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authenticatorData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, p.key, digest[:])
+	require.NoError(t, err)
+
+	encode := base64.RawURLEncoding.EncodeToString
+	credential, err := json.Marshal(map[string]any{
+		"id":    encode(p.credentialID),
+		"rawId": encode(p.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encode(clientData),
+			"authenticatorData": encode(authenticatorData),
+			"signature":         encode(signature),
+			"userHandle":        encode(p.userHandle),
+		},
+	})
+	require.NoError(t, err)
+	return credential
+}
+
+// testingClearPasskeys removes the passkeys of earlier test cases, as loading the
+// fixtures doesn't
+func testingClearPasskeys(t *testing.T, db *gorm.DB) {
+	err := db.Exec("DELETE FROM webauthn_credentials").Error
+	require.NoError(t, err)
+}
+
+// testingPasskeyOptionsRequest starts a passkey ceremony and returns its options
+func testingPasskeyOptionsRequest(t *testing.T, r http.Handler, targetURL string, token string, body any) testingPasskeyOptions {
+	req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, body)
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	return testingParsePasskeyOptions(t, w.Body.Bytes())
+}
+
+// testingRegisterPasskey registers a new passkey for the user of the token
+func testingRegisterPasskey(t *testing.T, r http.Handler, token string, password string) *testingPasskey {
+	options := testingPasskeyOptionsRequest(t, r, "/api/v1/auth/me/passkeys/options", token, PasskeyRegisterOptionsRequest{
+		Password: password,
+	})
+
+	passkey := newTestingPasskey(t)
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/passkeys", http.MethodPost, PasskeyRegisterRequest{
+		SessionToken: options.SessionToken,
+		Credential:   passkey.create(t, options),
+	})
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	return passkey
+}
+
+func TestPasskeyRegisterOptions(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name   string
+		token  string
+		body   PasskeyRegisterOptionsRequest
+		status int
+	}{
+		{
+			name:   "ok",
+			token:  validToken,
+			body:   PasskeyRegisterOptionsRequest{Password: "customer123"},
+			status: http.StatusOK,
+		},
+		{
+			name:   "wrong-password",
+			token:  validToken,
+			body:   PasskeyRegisterOptionsRequest{Password: "wrongpassword"},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "no-token",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			targetURL := "/api/v1/auth/me/passkeys/options"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"session_token":               xtesting.ValueBase64Token(256),
+				"options.publicKey.challenge": xtesting.ValueBase64Token(256),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+		})
+	}
+}
+
+func TestPasskeyRegisterOptionsLockout(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1ms")
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	options := func(password string) *httptest.ResponseRecorder {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/passkeys/options", http.MethodPost, PasskeyRegisterOptionsRequest{Password: password})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", validToken))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// A stolen access token can't be used to guess the password
+	for range 3 {
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, http.StatusUnauthorized, options("wrongpassword").Code)
+	}
+
+	user, err := models.GetUser(db, customerID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, user.FailedLoginAttempts)
+	assert.True(t, user.IsLocked())
+
+	w := options("customer123")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestPasskeyRegister(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	employeeID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	employeeToken, _ := auth.GenerateToken(employeeID, "employee@example.com", TestingMFALogin)
+
+	tests := []struct {
+		name         string
+		token        string
+		passkeyName  string
+		sessionToken string
+		challenge    string
+		status       int
+	}{
+		{
+			name:        "ok",
+			token:       customerToken,
+			passkeyName: "Laptop",
+			status:      http.StatusCreated,
+		},
+		{
+			name:   "ok-default-name",
+			token:  customerToken,
+			status: http.StatusCreated,
+		},
+		{
+			name:         "invalid-session-token",
+			token:        customerToken,
+			sessionToken: "invalid",
+			status:       http.StatusBadRequest,
+		},
+		{
+			name:   "session-of-other-user",
+			token:  employeeToken,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:      "wrong-challenge",
+			token:     customerToken,
+			challenge: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			status:    http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+			testingClearPasskeys(t, db)
+
+			options := testingPasskeyOptionsRequest(t, r, "/api/v1/auth/me/passkeys/options", customerToken, PasskeyRegisterOptionsRequest{
+				Password: "customer123",
+			})
+
+			sessionToken := options.SessionToken
+			if testCase.sessionToken != "" {
+				sessionToken = testCase.sessionToken
+			}
+			if testCase.challenge != "" {
+				options.Options.PublicKey.Challenge = testCase.challenge
+			}
+
+			targetURL := "/api/v1/auth/me/passkeys"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, PasskeyRegisterRequest{
+				SessionToken: sessionToken,
+				Name:         testCase.passkeyName,
+				Credential:   newTestingPasskey(t).create(t, options),
+			})
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"id":         xtesting.ValueUUID(),
+				"created_at": xtesting.ValueTimeInPastDuration(time.Minute),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+
+			count, err := models.CountUserWebAuthnCredentials(db, customerID)
+			require.NoError(t, err)
+			if testCase.status == http.StatusCreated {
+				assert.Equal(t, int64(1), count)
+			} else {
+				assert.Zero(t, count)
+			}
+		})
+	}
+}
+
+func TestPasskeyRegisterSessionIsSingleUse(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+	testingClearPasskeys(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	options := testingPasskeyOptionsRequest(t, r, "/api/v1/auth/me/passkeys/options", validToken, PasskeyRegisterOptionsRequest{
+		Password: "customer123",
+	})
+
+	register := func() int {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/passkeys", http.MethodPost, PasskeyRegisterRequest{
+			SessionToken: options.SessionToken,
+			Credential:   newTestingPasskey(t).create(t, options),
+		})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", validToken))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, register())
+	assert.Equal(t, http.StatusBadRequest, register())
+}
+
+func TestPasskeysList(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name     string
+		token    string
+		passkeys int
+		status   int
+	}{
+		{
+			name:     "ok",
+			token:    validToken,
+			passkeys: 2,
+			status:   http.StatusOK,
+		},
+		{
+			name:   "ok-empty",
+			token:  validToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "no-token",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+			testingClearPasskeys(t, db)
+
+			for range testCase.passkeys {
+				testingRegisterPasskey(t, r, validToken, "customer123")
+			}
+
+			targetURL := "/api/v1/auth/me/passkeys"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodGet, nil)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.GenerateValueCheckersForArrays(map[string]xtesting.ValueChecker{
+				"id":         xtesting.ValueUUID(),
+				"created_at": xtesting.ValueTimeInPastDuration(time.Minute),
+			}, testCase.passkeys)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+		})
+	}
+}
+
+func TestPasskeyDelete(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	employeeID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	employeeToken, _ := auth.GenerateToken(employeeID, "employee@example.com", TestingMFALogin)
+
+	tests := []struct {
+		name      string
+		token     string
+		passkeyID string
+		status    int
+	}{
+		{
+			name:   "ok",
+			token:  customerToken,
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "passkey-of-other-user",
+			token:  employeeToken,
+			status: http.StatusNotFound,
+		},
+		{
+			name:      "not-found",
+			token:     customerToken,
+			passkeyID: "00000000-0000-0000-0000-999999999999",
+			status:    http.StatusNotFound,
+		},
+		{
+			name:      "invalid-uuid",
+			token:     customerToken,
+			passkeyID: "invalid",
+			status:    http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+			testingClearPasskeys(t, db)
+
+			testingRegisterPasskey(t, r, customerToken, "customer123")
+			credentials, err := models.GetUserWebAuthnCredentials(db, customerID)
+			require.NoError(t, err)
+
+			passkeyID := testCase.passkeyID
+			if passkeyID == "" {
+				passkeyID = credentials[0].ID.String()
+			}
+
+			targetURL := fmt.Sprintf("/api/v1/auth/me/passkeys/%s", passkeyID)
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodDelete, nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			if testCase.status != http.StatusNoContent {
+				xtesting.AssertGoldenJSON(t, w)
+			}
+
+			count, err := models.CountUserWebAuthnCredentials(db, customerID)
+			require.NoError(t, err)
+			if testCase.status == http.StatusNoContent {
+				assert.Zero(t, count)
+			} else {
+				assert.Equal(t, int64(1), count)
+			}
+		})
+	}
+}
+
+func TestLoginPasskeyOptions(t *testing.T) {
+	db, _ := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/passkey/options", http.MethodPost, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ignoreResp := xtesting.ValuesCheckers{
+		"session_token":               xtesting.ValueBase64Token(256),
+		"options.publicKey.challenge": xtesting.ValueBase64Token(256),
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	xtesting.AssertGoldenJSON(t, w, ignoreResp)
+}
+
+func TestLoginPasskey(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name         string
+		sessionToken string
+		unregistered bool
+		inactive     bool
+		status       int
+	}{
+		{
+			name:   "ok",
+			status: http.StatusOK,
+		},
+		{
+			name:         "invalid-session-token",
+			sessionToken: "invalid",
+			status:       http.StatusUnauthorized,
+		},
+		{
+			name:         "unregistered-passkey",
+			unregistered: true,
+			status:       http.StatusUnauthorized,
+		},
+		{
+			name:     "inactive-user",
+			inactive: true,
+			status:   http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+			testingClearPasskeys(t, db)
+
+			passkey := testingRegisterPasskey(t, r, customerToken, "customer123")
+			if testCase.unregistered {
+				unregistered := newTestingPasskey(t)
+				unregistered.userHandle = passkey.userHandle
+				passkey = unregistered
+			}
+
+			if testCase.inactive {
+				err := db.Model(&models.User{}).Where("id = ?", customerID).Update("active", false).Error
+				require.NoError(t, err)
+			}
+
+			options := testingPasskeyOptionsRequest(t, r, "/api/v1/auth/login/passkey/options", "", nil)
+
+			sessionToken := options.SessionToken
+			if testCase.sessionToken != "" {
+				sessionToken = testCase.sessionToken
+			}
+
+			targetURL := "/api/v1/auth/login/passkey"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, LoginPasskeyRequest{
+				SessionToken: sessionToken,
+				Credential:   passkey.get(t, options),
+			})
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"access_token":  xtesting.ValueNotEqual(""),
+				"refresh_token": xtesting.ValueNotEqual(""),
+				"id_token":      xtesting.ValueNotEqual(""),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+
+			if testCase.status == http.StatusOK {
+				credentials, err := models.GetUserWebAuthnCredentials(db, customerID)
+				require.NoError(t, err)
+				assert.NotNil(t, credentials[0].LastUsedAt)
+				assert.Equal(t, uint32(1), credentials[0].Credential.Authenticator.SignCount)
+			}
+		})
+	}
+}
+
+func TestLoginPasskeyIsSingleUse(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+	testingClearPasskeys(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	passkey := testingRegisterPasskey(t, r, customerToken, "customer123")
+
+	options := testingPasskeyOptionsRequest(t, r, "/api/v1/auth/login/passkey/options", "", nil)
+	assertion := passkey.get(t, options)
+
+	login := func() int {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/passkey", http.MethodPost, LoginPasskeyRequest{
+			SessionToken: options.SessionToken,
+			Credential:   assertion,
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A replayed assertion is refused, even with a new session
+	assert.Equal(t, http.StatusOK, login())
+	assert.Equal(t, http.StatusUnauthorized, login())
+
+	options.SessionToken = testingPasskeyOptionsRequest(t, r, "/api/v1/auth/login/passkey/options", "", nil).SessionToken
+	assert.Equal(t, http.StatusUnauthorized, login())
+}
+
+func TestLoginMFAPasskey(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
+
+	tests := []struct {
+		name         string
+		unregistered bool
+		status       int
+	}{
+		{
+			name:   "ok",
+			status: http.StatusOK,
+		},
+		{
+			name:         "unregistered-passkey",
+			unregistered: true,
+			status:       http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+			testingClearPasskeys(t, db)
+
+			passkey := testingRegisterPasskey(t, r, adminToken, "admin123")
+			if testCase.unregistered {
+				passkey = newTestingPasskey(t)
+			}
+
+			// The password alone isn't enough for an admin with a passkey
+			req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+				Email:    "admin@example.com",
+				Password: "admin123",
+			})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var challenge MFAChallengeResponse
+			err = json.Unmarshal(w.Body.Bytes(), &challenge)
+			require.NoError(t, err)
+			require.True(t, challenge.MFARequired)
+
+			options := testingPasskeyOptionsRequest(t, r, "/api/v1/auth/login/mfa/passkey/options", "", LoginMFAPasskeyOptionsRequest{
+				MFAToken: challenge.MFAToken,
+			})
+
+			targetURL := "/api/v1/auth/login/mfa"
+
+			credential := passkey.get(t, options)
+			req = xtesting.NewTestingRequest(t, targetURL, http.MethodPost, LoginMFARequest{
+				MFAToken:     challenge.MFAToken,
+				SessionToken: options.SessionToken,
+				Credential:   &credential,
+			})
+			w = httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"access_token":  xtesting.ValueNotEqual(""),
+				"refresh_token": xtesting.ValueNotEqual(""),
+				"id_token":      xtesting.ValueNotEqual(""),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+		})
+	}
+}
+
+func TestLoginMFAPasskeyUnconfirmedTOTP(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+	testingClearPasskeys(t, db)
+
+	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
+	testingRegisterPasskey(t, r, adminToken, "admin123")
+
+	// A TOTP secret that was never confirmed isn't a second factor
+	user, err := models.GetUser(db, adminID)
+	require.NoError(t, err)
+	key, err := auth.GenerateTOTPKey(user.Email)
+	require.NoError(t, err)
+	err = user.StartTOTPEnrollment(db, key.Secret())
+	require.NoError(t, err)
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "admin@example.com",
+		Password: "admin123",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var challenge MFAChallengeResponse
+	err = json.Unmarshal(w.Body.Bytes(), &challenge)
+	require.NoError(t, err)
+	require.True(t, challenge.MFARequired)
+
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa", http.MethodPost, LoginMFARequest{
+		MFAToken: challenge.MFAToken,
+		Code:     testingTOTPCode(t, key.Secret()),
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLoginMFAPasskeyOptions(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+	testingClearPasskeys(t, db)
+
+	// A customer with TOTP can't answer the challenge with a passkey they don't have
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	testingEnableMFA(t, db, customerID)
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login/mfa/passkey/options", http.MethodPost, LoginMFAPasskeyOptionsRequest{
+		MFAToken: testingMFAChallenge(t, r),
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestLoginCustomerPasskeyIsNotSecondFactor(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+	testingClearPasskeys(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	testingRegisterPasskey(t, r, customerToken, "customer123")
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: "customer123",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var tokens TokenResponse
+	err = json.Unmarshal(w.Body.Bytes(), &tokens)
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
+
+func TestPasskeyDeleteRequiredByRole(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+	testingClearPasskeys(t, db)
+
+	employeeID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	employeeToken, _ := auth.GenerateToken(employeeID, "employee@example.com", TestingMFALogin)
+	testingRegisterPasskey(t, r, employeeToken, "employee123")
+
+	credentials, err := models.GetUserWebAuthnCredentials(db, employeeID)
+	require.NoError(t, err)
+
+	// The only second factor of an employee can't be removed
+	targetURL := fmt.Sprintf("/api/v1/auth/me/passkeys/%s", credentials[0].ID)
+
+	req := xtesting.NewTestingRequest(t, targetURL, http.MethodDelete, nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", employeeToken))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+
+	count, err := models.CountUserWebAuthnCredentials(db, employeeID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/auth/password"
+	"github.com/PRPO-skupina-02/common/middleware"
+	"github.com/gin-gonic/gin"
+	ut "github.com/go-playground/universal-translator"
+	"gorm.io/gorm"
+)
+
+// passwordTranslations are the messages of password policy violations. {0} is the
+// field and {1} the limit of the rule.
+var passwordTranslations = map[password.Violation]string{
+	password.ViolationTooShort:         "{0} must be at least {1} characters in length",
+	password.ViolationTooLong:          "{0} must be at most {1} bytes in length",
+	password.ViolationCharacterClasses: "{0} must contain at least {1} of lowercase letters, uppercase letters, digits and symbols",
+	password.ViolationPersonalInfo:     "{0} must not contain your email address or name",
+	password.ViolationTooWeak:          "{0} is too easy to guess",
+	password.ViolationBreached:         "{0} has appeared in a data breach and must not be used",
+	password.ViolationReused:           "{0} must not be a password you have used recently",
+}
+
+// registerPasswordTranslations adds the password policy messages to the
+// translator. The messages are constant, so an error is a bug.
+func registerPasswordTranslations(trans ut.Translator) {
+	for violation, text := range passwordTranslations {
+		if err := trans.Add(string(violation), text, true); err != nil {
+			panic(fmt.Sprintf("invalid translation of %s: %v", violation, err))
+		}
+	}
+}
+
+// validatePassword checks a new password against the password policy. Violations
+// are returned as a validation error of the field, like those of request binding.
+// Personal are the email address and names of the user.
+func validatePassword(c *gin.Context, field string, value string, personal ...string) error {
+	err := password.GetPolicy().Check(value, personal...)
+
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return err
+	}
+	return passwordValidationError(c, field, policyErr)
+}
+
+// validatePasswordChange checks the new password of an existing user against
+// the password policy and their password history
+func validatePasswordChange(c *gin.Context, tx *gorm.DB, user models.User, field string, value string) error {
+	if err := validatePassword(c, field, value, user.Email, user.FirstName, user.LastName); err != nil {
+		return err
+	}
+
+	reused, err := user.IsPasswordReused(tx, value, password.GetHistorySize())
+	if err != nil {
+		return err
+	}
+	if reused {
+		return passwordValidationError(c, field, &password.PolicyError{Violation: password.ViolationReused})
+	}
+	return nil
+}
+
+// passwordValidationError translates a policy violation into a validation error
+// of the field
+func passwordValidationError(c *gin.Context, field string, policyErr *password.PolicyError) error {
+	params := append([]string{field}, policyErr.Params...)
+	message, err := middleware.GetContextTranslation(c).T(string(policyErr.Violation), params...)
+	if err != nil {
+		message = policyErr.Error()
+	}
+
+	return &middleware.HttpError{
+		Code:    http.StatusBadRequest,
+		Message: "validation error",
+		Fields:  map[string]string{field: message},
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/auth/password"
+	"github.com/PRPO-skupina-02/common/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// passwordResetInterval is the minimum time between two reset emails to the same user
+const passwordResetInterval = time.Minute
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// sendPasswordResetEmail issues a new reset token, invalidating older ones, and
+// emails the user a link to the frontend reset page
+func sendPasswordResetEmail(tx *gorm.DB, user models.User) error {
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	if err := models.InvalidateUserPasswordResetTokens(tx, user.ID); err != nil {
+		return err
+	}
+
+	resetToken := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(auth.GetPasswordResetTTL()),
+	}
+	if err := resetToken.Create(tx); err != nil {
+		return err
+	}
+
+	sendEmail(user.Email, "password-reset", map[string]interface{}{
+		"Subject":   "Reset your password",
+		"UserName":  user.FirstName,
+		"ResetLink": fmt.Sprintf("%s/reset-password?token=%s", getFrontendURL(), url.QueryEscape(token)),
+	})
+
+	return nil
+}
+
+// ForgotPassword
+//
+//	@Id				ForgotPassword
+//	@Summary		Request password reset
+//	@Description	Email a password reset link to the account. The response is the same whether or not the account exists, and emails to the same account are throttled.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		ForgotPasswordRequest	true	"Email address"
+//	@Success		202		{object}	object{message=string}
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		413		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/password/forgot [post]
+func ForgotPassword(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := models.GetUserByEmail(tx, req.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = c.Error(err)
+		return
+	}
+
+	if err == nil && user.Active {
+		requested, err := models.PasswordResetRequestedSince(tx, user.ID, time.Now().Add(-passwordResetInterval))
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		if !requested {
+			if err := sendPasswordResetEmail(tx, user); err != nil {
+				_ = c.Error(err)
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a password reset email has been sent"})
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ResetPassword
+//
+//	@Id				ResetPassword
+//	@Summary		Reset password
+//	@Description	Set a new password with the token from the reset email. The token can only be used once, recently used passwords are rejected, all existing sessions of the user are ended, and a lock after failed logins is lifted.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		ResetPasswordRequest	true	"Reset token and new password"
+//	@Success		200		{object}	object{message=string}
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/password/reset [post]
+func ResetPassword(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resetToken, err := models.GetPasswordResetTokenByHash(tx, auth.HashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		_ = c.Error(err)
+		return
+	}
+
+	if !resetToken.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	user, err := models.GetUser(tx, resetToken.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if !user.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	if err := validatePasswordChange(c, tx, user, "new_password", req.NewPassword); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := resetToken.MarkUsed(tx); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := user.ReplacePassword(tx, req.NewPassword, password.GetHistorySize()); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := user.Save(tx); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// Failed logins of whoever guessed at the old password no longer lock the owner out
+	if err := user.ResetFailedLogins(tx); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// Whoever had the old password may still be logged in
+	if err := user.RevokeAllTokens(tx); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// Following the emailed link proves the user owns the address
+	if !user.IsEmailVerified() {
+		if err := user.MarkEmailVerified(tx); err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
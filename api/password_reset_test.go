@@ -0,0 +1,230 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// testingPasswordResetToken issues a reset token for the user expiring at the given time
+func testingPasswordResetToken(t *testing.T, db *gorm.DB, userID uuid.UUID, expiresAt time.Time) (string, models.PasswordResetToken) {
+	token, err := auth.GenerateOpaqueToken()
+	require.NoError(t, err)
+
+	resetToken := models.PasswordResetToken{
+		UserID:    userID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: expiresAt,
+	}
+	err = resetToken.Create(db)
+	require.NoError(t, err)
+
+	return token, resetToken
+}
+
+func TestForgotPassword(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	tests := []struct {
+		name   string
+		body   any
+		status int
+	}{
+		{
+			name:   "ok",
+			body:   ForgotPasswordRequest{Email: "customer@example.com"},
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "ok-throttled",
+			body:   ForgotPasswordRequest{Email: "customer@example.com"},
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "unknown-email",
+			body:   ForgotPasswordRequest{Email: "nonexistent@example.com"},
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "validation-error-email",
+			body:   ForgotPasswordRequest{Email: "invalid-email"},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			targetURL := "/api/v1/auth/password/forgot"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := testingPasswordResetToken(t, db, customerID, time.Now().Add(time.Hour))
+	shortPasswordToken, _ := testingPasswordResetToken(t, db, customerID, time.Now().Add(time.Hour))
+	reusedPasswordToken, _ := testingPasswordResetToken(t, db, customerID, time.Now().Add(time.Hour))
+	expiredToken, _ := testingPasswordResetToken(t, db, customerID, time.Now().Add(-time.Minute))
+	usedToken, usedResetToken := testingPasswordResetToken(t, db, customerID, time.Now().Add(time.Hour))
+	err = usedResetToken.MarkUsed(db)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		body   any
+		status int
+	}{
+		{
+			name:   "ok",
+			body:   ResetPasswordRequest{Token: validToken, NewPassword: "newpassword123"},
+			status: http.StatusOK,
+		},
+		{
+			name:   "reused-token",
+			body:   ResetPasswordRequest{Token: validToken, NewPassword: "otherpassword123"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "used-token",
+			body:   ResetPasswordRequest{Token: usedToken, NewPassword: "newpassword123"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "expired-token",
+			body:   ResetPasswordRequest{Token: expiredToken, NewPassword: "newpassword123"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown-token",
+			body:   ResetPasswordRequest{Token: "unknown", NewPassword: "newpassword123"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "validation-error-password-too-short",
+			body:   ResetPasswordRequest{Token: shortPasswordToken, NewPassword: "short"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "validation-error-password-reused",
+			body:   ResetPasswordRequest{Token: reusedPasswordToken, NewPassword: "newpassword123"},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			targetURL := "/api/v1/auth/password/reset"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+		})
+	}
+}
+
+func TestResetPasswordEndsSessions(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	accessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	resetToken, _ := testingPasswordResetToken(t, db, customerID, time.Now().Add(time.Hour))
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/password/reset", http.MethodPost, ResetPasswordRequest{
+		Token:       resetToken,
+		NewPassword: "newpassword123",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/me", http.MethodGet, nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: "newpassword123",
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestResetPasswordUnlocksAccount(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	user, err := models.GetUser(db, customerID)
+	require.NoError(t, err)
+	err = db.Model(&user).Update("failed_login_attempts", 10).Error
+	require.NoError(t, err)
+	err = user.LockUntil(db, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	resetToken, _ := testingPasswordResetToken(t, db, customerID, time.Now().Add(time.Hour))
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/password/reset", http.MethodPost, ResetPasswordRequest{
+		Token:       resetToken,
+		NewPassword: "newpassword123",
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	user, err = models.GetUser(db, customerID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, user.FailedLoginAttempts)
+	assert.False(t, user.IsLocked())
+
+	req = xtesting.NewTestingRequest(t, "/api/v1/auth/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: "newpassword123",
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
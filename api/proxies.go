@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// GetTrustedProxies returns the addresses and CIDR ranges of the reverse proxies
+// whose X-Forwarded-For header is believed, from a comma separated list. None are
+// trusted by default, so clients can't pick their own IP address for rate limits
+// and login blocking, and the client IP is the address of the connection.
+func GetTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// ValidateProxyConfig reports every trusted proxy that isn't an IP address or
+// CIDR range
+func ValidateProxyConfig() error {
+	var errs []error
+	for _, proxy := range GetTrustedProxies() {
+		if net.ParseIP(proxy) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			errs = append(errs, fmt.Errorf("TRUSTED_PROXIES entry %q must be an IP address or CIDR range", proxy))
+		}
+	}
+	return errors.Join(errs...)
+}
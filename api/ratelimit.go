@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/auth/ratelimit"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// rateLimits are the limits of one route. Every client IP address has its own
+// bucket, and on routes taking an email address every address has one as well,
+// so a single account can't be targeted from many addresses. Each limit can be
+// overridden with RATE_LIMIT_<Name>_IP and RATE_LIMIT_<Name>_EMAIL.
+type rateLimits struct {
+	Name  string
+	IP    ratelimit.Limit
+	Email *ratelimit.Limit
+}
+
+var (
+	registerLimits = rateLimits{
+		Name:  "REGISTER",
+		IP:    ratelimit.Limit{Requests: 10, Per: time.Hour},
+		Email: &ratelimit.Limit{Requests: 3, Per: time.Hour},
+	}
+	loginLimits = rateLimits{
+		Name:  "LOGIN",
+		IP:    ratelimit.Limit{Requests: 20, Per: time.Minute},
+		Email: &ratelimit.Limit{Requests: 10, Per: time.Minute},
+	}
+	refreshLimits = rateLimits{
+		Name: "REFRESH",
+		IP:   ratelimit.Limit{Requests: 30, Per: time.Minute},
+	}
+	// Services check the token of every request they serve, like introspection
+	verifyLimits = rateLimits{
+		Name: "VERIFY",
+		IP:   ratelimit.Limit{Requests: 600, Per: time.Minute},
+	}
+	introspectLimits = rateLimits{
+		Name: "INTROSPECT",
+		IP:   ratelimit.Limit{Requests: 600, Per: time.Minute},
+	}
+	emailLinkLimits = rateLimits{
+		Name:  "EMAIL_LINK",
+		IP:    ratelimit.Limit{Requests: 10, Per: time.Hour},
+		Email: &ratelimit.Limit{Requests: 5, Per: time.Hour},
+	}
+	tokenLimits = rateLimits{
+		Name: "TOKEN",
+		IP:   ratelimit.Limit{Requests: 10, Per: time.Minute},
+	}
+)
+
+// configured returns the limits with the overrides from the environment applied
+func (l rateLimits) configured() rateLimits {
+	limits := rateLimits{
+		Name: l.Name,
+		IP:   ratelimit.GetLimitEnv("RATE_LIMIT_"+l.Name+"_IP", l.IP),
+	}
+	if l.Email != nil {
+		email := ratelimit.GetLimitEnv("RATE_LIMIT_"+l.Name+"_EMAIL", *l.Email)
+		limits.Email = &email
+	}
+	return limits
+}
+
+// newRateLimitStore returns the store of the configured rate limiting backend
+func newRateLimitStore(db *gorm.DB) ratelimit.Store {
+	if ratelimit.GetBackend() == ratelimit.BackendPostgres {
+		return models.NewRateLimitStore(db)
+	}
+	return ratelimit.NewMemoryStore()
+}
+
+// maxEmailRequestSize is the most of the body read to find the email address. Bodies
+// of routes taking one are far smaller, and the body is read before any limit applies.
+const maxEmailRequestSize = 8 << 10
+
+// requestEmail returns the email address in the JSON body, leaving the body in
+// place for the handler. It fails if the body is larger than maxEmailRequestSize.
+func requestEmail(c *gin.Context) (string, error) {
+	if c.Request.Body == nil {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxEmailRequestSize))
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return "", err
+		}
+		return "", nil
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", nil
+	}
+	return strings.ToLower(strings.TrimSpace(req.Email)), nil
+}
+
+// RateLimit throttles the route, responding with 429 and Retry-After once one of
+// the buckets of the request is empty. If the store fails, requests are refused
+// with 503, as letting them through would turn off brute force protection.
+func RateLimit(store ratelimit.Store, limits rateLimits) gin.HandlerFunc {
+	limits = limits.configured()
+
+	return func(c *gin.Context) {
+		route := c.FullPath()
+
+		buckets := map[string]ratelimit.Limit{
+			"ip:" + route + ":" + c.ClientIP(): limits.IP,
+		}
+		if limits.Email != nil {
+			email, err := requestEmail(c)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+				return
+			}
+			if email != "" {
+				buckets["email:"+route+":"+email] = *limits.Email
+			}
+		}
+
+		var retryAfter time.Duration
+		for key, limit := range buckets {
+			allowed, wait, err := store.Take(key, limit)
+			if err != nil {
+				slog.Error("Failed to check rate limit", "key", key, "error", err)
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Service unavailable, try again later"})
+				return
+			}
+			if !allowed {
+				retryAfter = max(retryAfter, wait)
+			}
+		}
+
+		if retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(max(int(math.Ceil(retryAfter.Seconds())), 1)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, try again later"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/auth/ratelimit"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	targetURL := "/api/v1/auth/password/forgot"
+
+	// Addresses differing only in case share a bucket
+	for _, email := range []string{"customer@example.com", "Customer@example.com", "CUSTOMER@EXAMPLE.COM", "customer@example.com", "customer@example.com"} {
+		req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, ForgotPasswordRequest{Email: email})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusAccepted, w.Code)
+	}
+
+	req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, ForgotPasswordRequest{Email: "customer@example.com"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	xtesting.AssertGoldenJSON(t, w)
+
+	// Other addresses still have requests left from the same IP address
+	req = xtesting.NewTestingRequest(t, targetURL, http.MethodPost, ForgotPasswordRequest{Email: "employee@example.com"})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(key string, limit ratelimit.Limit) (bool, time.Duration, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+// testingRateLimitRouter serves a route that only answers once the rate limit lets it through
+func testingRateLimitRouter(t *testing.T, store ratelimit.Store, limits rateLimits) *gin.Engine {
+	r := gin.New()
+	err := r.SetTrustedProxies(GetTrustedProxies())
+	require.NoError(t, err)
+	r.POST("/login", RateLimit(store, limits), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	})
+	return r
+}
+
+func TestRateLimitStoreFailure(t *testing.T) {
+	r := testingRateLimitRouter(t, failingRateLimitStore{}, loginLimits)
+
+	req := xtesting.NewTestingRequest(t, "/login", http.MethodPost, LoginRequest{Email: "customer@example.com"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Requests aren't let through unchecked while the store is unavailable
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestRateLimitVerifyServiceBurst(t *testing.T) {
+	r := testingRateLimitRouter(t, ratelimit.NewMemoryStore(), verifyLimits)
+
+	// A service verifying the token of every request it serves isn't throttled
+	for range 500 {
+		req := xtesting.NewTestingRequest(t, "/login", http.MethodPost, gin.H{"token": "token"})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+func TestRateLimitBodyTooLarge(t *testing.T) {
+	r := testingRateLimitRouter(t, ratelimit.NewMemoryStore(), loginLimits)
+
+	// The body is read to find the email address before any limit applies, so it isn't read whole
+	req := xtesting.NewTestingRequest(t, "/login", http.MethodPost, LoginRequest{
+		Email:    "customer@example.com",
+		Password: strings.Repeat("a", 1<<20),
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestRateLimitConfigured(t *testing.T) {
+	t.Setenv("RATE_LIMIT_LOGIN_IP", "100/1m")
+	t.Setenv("RATE_LIMIT_LOGIN_EMAIL", "1/1h")
+
+	r := testingRateLimitRouter(t, ratelimit.NewMemoryStore(), loginLimits)
+
+	login := func(email string) int {
+		req := xtesting.NewTestingRequest(t, "/login", http.MethodPost, LoginRequest{Email: email})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, login("customer@example.com"))
+	assert.Equal(t, http.StatusTooManyRequests, login("customer@example.com"))
+	assert.Equal(t, http.StatusOK, login("employee@example.com"))
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	t.Setenv("RATE_LIMIT_LOGIN_IP", "2/1m")
+
+	login := func(r *gin.Engine, email, forwardedFor string) int {
+		req := xtesting.NewTestingRequest(t, "/login", http.MethodPost, LoginRequest{Email: email})
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A client can't get a fresh bucket by making up its own X-Forwarded-For
+	r := testingRateLimitRouter(t, ratelimit.NewMemoryStore(), loginLimits)
+	assert.Equal(t, http.StatusOK, login(r, "customer@example.com", "198.51.100.1"))
+	assert.Equal(t, http.StatusOK, login(r, "employee@example.com", "198.51.100.2"))
+	assert.Equal(t, http.StatusTooManyRequests, login(r, "admin@example.com", "198.51.100.3"))
+
+	// Behind a trusted proxy every client has its own bucket
+	t.Setenv("TRUSTED_PROXIES", "192.0.2.0/24")
+	r = testingRateLimitRouter(t, ratelimit.NewMemoryStore(), loginLimits)
+	assert.Equal(t, http.StatusOK, login(r, "customer@example.com", "198.51.100.1"))
+	assert.Equal(t, http.StatusOK, login(r, "employee@example.com", "198.51.100.1"))
+	assert.Equal(t, http.StatusOK, login(r, "admin@example.com", "198.51.100.2"))
+}
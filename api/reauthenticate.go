@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type ReauthenticateRequest struct {
+	// RefreshToken is the refresh token of the session being reauthenticated
+	RefreshToken string `json:"refresh_token" binding:"required"`
+	Password     string `json:"password" binding:"required"`
+	// Code is a TOTP or recovery code, needed from users with MFA unless they
+	// present a passkey
+	Code string `json:"code"`
+	// SessionToken and Credential are the session token of
+	// /reauthenticate/passkey/options and the result of navigator.credentials.get
+	SessionToken string           `json:"session_token" binding:"required_with=Credential"`
+	Credential   *json.RawMessage `json:"credential" swaggertype:"object"`
+}
+
+// Reauthenticate
+//
+//	@Id				Reauthenticate
+//	@Summary		Reauthenticate
+//	@Description	Confirm the password, and the TOTP or recovery code or a passkey of users with MFA, to get JWT tokens fresh enough for sensitive operations such as changing the password. The refresh token of the session is rotated and the presented access token revoked, so the session continues with the new tokens. Wrong passwords and codes count as failed logins of the account.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		ReauthenticateRequest	true	"Password and MFA code or passkey assertion"
+//	@Success		200		{object}	TokenResponse
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		401		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/reauthenticate [post]
+func Reauthenticate(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	claims := GetContextClaims(c)
+
+	var req ReauthenticateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	storedToken, _, ok := getRefreshTokenSession(c, tx, req.RefreshToken)
+	if !ok {
+		return
+	}
+	if storedToken.UserID != claims.UserID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	ipAddress := c.ClientIP()
+	ipFailure, err := models.GetIPLoginFailure(tx, ipAddress)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if ipFailure.IsBlocked() {
+		tooManyLoginAttempts(c, *ipFailure.BlockedUntil)
+		return
+	}
+
+	user, err := models.GetUser(tx, claims.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if !checkUserPassword(c, tx, &user, req.Password) {
+		return
+	}
+
+	secondFactor, err := requiresSecondFactor(tx, user)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	amr := []string{auth.AMRPassword}
+	if secondFactor {
+		var valid bool
+		method := auth.AMROTP
+		if req.Credential != nil {
+			method = auth.AMRHardwareKey
+			valid, err = verifyMFAPasskey(tx, user, req.SessionToken, *req.Credential)
+		} else {
+			valid, err = verifyMFACode(tx, &user, req.Code)
+		}
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		if !valid {
+			if err := recordFailedLogin(tx, ipAddress, user.Email); err != nil {
+				_ = c.Error(err)
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+			return
+		}
+		amr = withSecondFactor(amr, method)
+	}
+
+	if !resetFailedLogins(c, tx, &user) {
+		return
+	}
+
+	// Continue the session with the new tokens rather than starting another one
+	if err := storedToken.Revoke(tx); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := revokeAccessToken(tx, claims); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	tokens, err := issueTokens(c, tx, user, storedToken.FamilyID, &storedToken.ID, amr, time.Now())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// ReauthenticatePasskeyOptions
+//
+//	@Id				ReauthenticatePasskeyOptions
+//	@Summary		Start passkey reauthentication
+//	@Description	Start confirming a reauthentication with a passkey instead of a TOTP code. Pass the options to navigator.credentials.get and send the result to POST /reauthenticate together with the password.
+//	@Tags			auth
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	PasskeyOptionsResponse
+//	@Failure		400	{object}	middleware.HttpError
+//	@Failure		401	{object}	middleware.HttpError
+//	@Failure		429	{object}	middleware.HttpError
+//	@Failure		500	{object}	middleware.HttpError
+//	@Failure		503	{object}	middleware.HttpError
+//	@Router			/reauthenticate/passkey/options [post]
+func ReauthenticatePasskeyOptions(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := GetContextUserID(c)
+
+	user, err := models.GetUser(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	startMFAPasskeyCeremony(c, tx, user)
+}
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReauthenticate(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	tests := []struct {
+		name     string
+		noToken  bool
+		session  string
+		mfa      bool
+		password string
+		code     func(t *testing.T, secret string, recoveryCodes []string) string
+		status   int
+	}{
+		{
+			name:     "ok",
+			password: "customer123",
+			status:   http.StatusOK,
+		},
+		{
+			name:     "ok-totp",
+			mfa:      true,
+			password: "customer123",
+			code: func(t *testing.T, secret string, recoveryCodes []string) string {
+				return testingTOTPCode(t, secret)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:     "ok-recovery-code",
+			mfa:      true,
+			password: "customer123",
+			code: func(t *testing.T, secret string, recoveryCodes []string) string {
+				return recoveryCodes[0]
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:     "wrong-password",
+			password: "wrongpassword",
+			status:   http.StatusUnauthorized,
+		},
+		{
+			name:     "missing-code",
+			mfa:      true,
+			password: "customer123",
+			status:   http.StatusUnauthorized,
+		},
+		{
+			name:     "wrong-code",
+			mfa:      true,
+			password: "customer123",
+			code: func(t *testing.T, secret string, recoveryCodes []string) string {
+				return "000000"
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:     "invalid-session",
+			session:  "invalid",
+			password: "customer123",
+			status:   http.StatusUnauthorized,
+		},
+		{
+			name:     "other-users-session",
+			session:  "other-user",
+			password: "customer123",
+			status:   http.StatusUnauthorized,
+		},
+		{
+			name:    "validation-error",
+			session: "none",
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:     "no-token",
+			noToken:  true,
+			password: "customer123",
+			status:   http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			// Reauthenticating revokes the access token, so every case gets its own
+			accessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+			refreshToken, storedToken := TestingRefreshToken(t, db, customerID, "customer@example.com")
+
+			body := ReauthenticateRequest{Password: testCase.password}
+			switch testCase.session {
+			case "":
+				body.RefreshToken = refreshToken
+			case "invalid":
+				body.RefreshToken = "invalid"
+			case "other-user":
+				body.RefreshToken, _ = TestingRefreshToken(t, db, adminID, "admin@example.com")
+			}
+			if testCase.mfa {
+				secret, recoveryCodes := testingEnableMFA(t, db, customerID)
+				if testCase.code != nil {
+					body.Code = testCase.code(t, secret, recoveryCodes)
+				}
+			}
+
+			targetURL := "/api/v1/auth/reauthenticate"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, body)
+			if !testCase.noToken {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			ignoreResp := xtesting.ValuesCheckers{
+				"access_token":  xtesting.ValueNotEqual(""),
+				"refresh_token": xtesting.ValueNotEqual(""),
+				"id_token":      xtesting.ValueNotEqual(""),
+			}
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w, ignoreResp)
+
+			if testCase.status == http.StatusOK {
+				var tokens TokenResponse
+				err = json.Unmarshal(w.Body.Bytes(), &tokens)
+				require.NoError(t, err)
+
+				claims, err := auth.ValidateToken(tokens.AccessToken, auth.TokenTypeAccess)
+				require.NoError(t, err)
+				assert.True(t, hasRecentAuth(claims, auth.GetRecentAuthMaxAge()))
+				assert.Equal(t, testCase.mfa, claims.HasMFA())
+
+				// The session continues in the same family, with the presented tokens revoked
+				rotated, err := models.GetRefreshTokenByHash(db, auth.HashToken(tokens.RefreshToken))
+				require.NoError(t, err)
+				assert.Equal(t, storedToken.FamilyID, rotated.FamilyID)
+
+				presented, err := models.GetRefreshTokenByHash(db, auth.HashToken(refreshToken))
+				require.NoError(t, err)
+				assert.True(t, presented.IsRevoked())
+
+				_, err = auth.ValidateToken(accessToken, auth.TokenTypeAccess)
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestReauthenticateLockout(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1ms")
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	testingEnableMFA(t, db, customerID)
+
+	accessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	refreshToken, _ := TestingRefreshToken(t, db, customerID, "customer@example.com")
+
+	reauthenticate := func(code string) *httptest.ResponseRecorder {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/reauthenticate", http.MethodPost, ReauthenticateRequest{
+			RefreshToken: refreshToken,
+			Password:     "customer123",
+			Code:         code,
+		})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The right password doesn't reset the failed logins before the code is checked
+	for range 3 {
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, http.StatusUnauthorized, reauthenticate("000000").Code)
+	}
+
+	user, err := models.GetUser(db, customerID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, user.FailedLoginAttempts)
+	assert.True(t, user.IsLocked())
+
+	w := reauthenticate("000000")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestReauthenticateAllowsSensitiveOperations(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	accessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	refreshToken, _ := TestingRefreshToken(t, db, customerID, "customer@example.com")
+
+	changePassword := func(token string) int {
+		req := xtesting.NewTestingRequest(t, "/api/v1/auth/me/password", http.MethodPut, ChangePasswordRequest{
+			OldPassword: "customer123",
+			NewPassword: "zebra-lamp-42",
+		})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Tokens without a login time are never recent enough
+	require.Equal(t, http.StatusUnauthorized, changePassword(accessToken))
+
+	req := xtesting.NewTestingRequest(t, "/api/v1/auth/reauthenticate", http.MethodPost, ReauthenticateRequest{
+		RefreshToken: refreshToken,
+		Password:     "customer123",
+	})
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var tokens TokenResponse
+	err = json.Unmarshal(w.Body.Bytes(), &tokens)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, changePassword(tokens.AccessToken))
+}
+
+func TestReauthenticatePasskeyOptions(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	accessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{
+			name:   "no-passkeys",
+			token:  accessToken,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "no-token",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			require.NoError(t, err)
+
+			targetURL := "/api/v1/auth/reauthenticate/passkey/options"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, nil)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+		})
+	}
+}
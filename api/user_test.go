@@ -21,7 +21,7 @@ func TestUsersList(t *testing.T) {
 	r := TestingRouter(t, db)
 
 	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
-	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
 
 	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
 	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
@@ -86,7 +86,7 @@ func TestUsersShow(t *testing.T) {
 	r := TestingRouter(t, db)
 
 	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
-	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
 
 	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
 	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
@@ -154,7 +154,8 @@ func TestAdminCreateUser(t *testing.T) {
 	r := TestingRouter(t, db)
 
 	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
-	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin, TestingRecentAuth())
+	staleAdminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
 
 	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
 	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
@@ -170,7 +171,7 @@ func TestAdminCreateUser(t *testing.T) {
 			token: adminToken,
 			body: AdminCreateUserRequest{
 				Email:     "newemployee@example.com",
-				Password:  "password123",
+				Password:  "newpassword123",
 				FirstName: "New",
 				LastName:  "Employee",
 				Role:      string(models.RoleEmployee),
@@ -183,7 +184,7 @@ func TestAdminCreateUser(t *testing.T) {
 			token: adminToken,
 			body: AdminCreateUserRequest{
 				Email:     "newcustomer@example.com",
-				Password:  "password123",
+				Password:  "newpassword123",
 				FirstName: "New",
 				LastName:  "Customer",
 				Role:      string(models.RoleCustomer),
@@ -196,7 +197,7 @@ func TestAdminCreateUser(t *testing.T) {
 			token: adminToken,
 			body: AdminCreateUserRequest{
 				Email:     "newadmin@example.com",
-				Password:  "password123",
+				Password:  "newpassword123",
 				FirstName: "New",
 				LastName:  "Admin",
 				Role:      string(models.RoleAdmin),
@@ -209,7 +210,7 @@ func TestAdminCreateUser(t *testing.T) {
 			token: adminToken,
 			body: AdminCreateUserRequest{
 				Email:     "customer@example.com",
-				Password:  "password123",
+				Password:  "newpassword123",
 				FirstName: "Duplicate",
 				LastName:  "User",
 				Role:      string(models.RoleEmployee),
@@ -222,7 +223,7 @@ func TestAdminCreateUser(t *testing.T) {
 			token: adminToken,
 			body: AdminCreateUserRequest{
 				Email:     "invalid-email",
-				Password:  "password123",
+				Password:  "newpassword123",
 				FirstName: "Test",
 				LastName:  "User",
 				Role:      string(models.RoleEmployee),
@@ -243,12 +244,38 @@ func TestAdminCreateUser(t *testing.T) {
 			},
 			status: http.StatusBadRequest,
 		},
+		{
+			name:  "ok-customer-not-recent-auth",
+			token: staleAdminToken,
+			body: AdminCreateUserRequest{
+				Email:     "newcustomer@example.com",
+				Password:  "newpassword123",
+				FirstName: "New",
+				LastName:  "Customer",
+				Role:      string(models.RoleCustomer),
+				Active:    true,
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:  "employee-not-recent-auth",
+			token: staleAdminToken,
+			body: AdminCreateUserRequest{
+				Email:     "newemployee@example.com",
+				Password:  "newpassword123",
+				FirstName: "New",
+				LastName:  "Employee",
+				Role:      string(models.RoleEmployee),
+				Active:    true,
+			},
+			status: http.StatusUnauthorized,
+		},
 		{
 			name:  "forbidden-customer",
 			token: customerToken,
 			body: AdminCreateUserRequest{
 				Email:     "test@example.com",
-				Password:  "password123",
+				Password:  "newpassword123",
 				FirstName: "Test",
 				LastName:  "User",
 				Role:      string(models.RoleEmployee),
@@ -298,13 +325,18 @@ func TestUsersUpdate(t *testing.T) {
 	r := TestingRouter(t, db)
 
 	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
-	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin, TestingRecentAuth())
+	staleAdminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
 
 	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
 	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
 
 	firstName := "UpdatedName"
 	active := false
+	newEmail := "newcustomer@example.com"
+	takenEmail := "employee@example.com"
+	newPassword := "zebra-lamp-42"
+	shortPassword := "short"
 
 	tests := []struct {
 		name   string
@@ -331,6 +363,69 @@ func TestUsersUpdate(t *testing.T) {
 			},
 			status: http.StatusOK,
 		},
+		{
+			name:   "ok-email",
+			token:  adminToken,
+			userID: "00000000-0000-0000-0000-000000000003",
+			body: AdminUpdateUserRequest{
+				Email: &newEmail,
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "duplicate-email",
+			token:  adminToken,
+			userID: "00000000-0000-0000-0000-000000000003",
+			body: AdminUpdateUserRequest{
+				Email: &takenEmail,
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name:   "ok-password",
+			token:  adminToken,
+			userID: "00000000-0000-0000-0000-000000000003",
+			body: AdminUpdateUserRequest{
+				Password: &newPassword,
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "validation-error-password-too-short",
+			token:  adminToken,
+			userID: "00000000-0000-0000-0000-000000000003",
+			body: AdminUpdateUserRequest{
+				Password: &shortPassword,
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "ok-not-recent-auth",
+			token:  staleAdminToken,
+			userID: "00000000-0000-0000-0000-000000000003",
+			body: AdminUpdateUserRequest{
+				FirstName: &firstName,
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "email-not-recent-auth",
+			token:  staleAdminToken,
+			userID: "00000000-0000-0000-0000-000000000003",
+			body: AdminUpdateUserRequest{
+				Email: &newEmail,
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "password-not-recent-auth",
+			token:  staleAdminToken,
+			userID: "00000000-0000-0000-0000-000000000003",
+			body: AdminUpdateUserRequest{
+				Password: &newPassword,
+			},
+			status: http.StatusUnauthorized,
+		},
 		{
 			name:   "not-found",
 			token:  adminToken,
@@ -390,7 +485,8 @@ func TestUsersDelete(t *testing.T) {
 	r := TestingRouter(t, db)
 
 	adminID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
-	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com")
+	adminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin, TestingRecentAuth())
+	staleAdminToken, _ := auth.GenerateToken(adminID, "admin@example.com", TestingMFALogin)
 
 	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
 	customerToken, _ := auth.GenerateToken(customerID, "customer@example.com")
@@ -413,6 +509,12 @@ func TestUsersDelete(t *testing.T) {
 			userID: "00000000-0000-0000-0000-999999999999",
 			status: http.StatusNotFound,
 		},
+		{
+			name:   "not-recent-auth",
+			token:  staleAdminToken,
+			userID: "00000000-0000-0000-0000-000000000003",
+			status: http.StatusUnauthorized,
+		},
 		{
 			name:   "forbidden-customer",
 			token:  customerToken,
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// UserInfoResponse holds the standard OpenID Connect claims about the user
+type UserInfoResponse struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	GivenName     string `json:"given_name,omitempty"`
+	FamilyName    string `json:"family_name,omitempty"`
+}
+
+func newUserInfoResponse(user models.User) UserInfoResponse {
+	return UserInfoResponse{
+		Subject:       user.ID.String(),
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified(),
+		GivenName:     user.FirstName,
+		FamilyName:    user.LastName,
+	}
+}
+
+func newIDTokenClaims(user models.User) auth.IDTokenClaims {
+	info := newUserInfoResponse(user)
+
+	return auth.IDTokenClaims{
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		GivenName:     info.GivenName,
+		FamilyName:    info.FamilyName,
+	}
+}
+
+// UserInfo
+//
+//	@Id				UserInfo
+//	@Summary		OpenID Connect user info
+//	@Description	Standard claims about the authenticated user
+//	@Tags			discovery
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	UserInfoResponse
+//	@Failure		401	{object}	middleware.HttpError
+//	@Failure		500	{object}	middleware.HttpError
+//	@Router			/userinfo [get]
+//	@Router			/userinfo [post]
+func UserInfo(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+	userID := GetContextUserID(c)
+
+	user, err := models.GetUser(tx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserInfoResponse(user))
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserInfo(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	validToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+	idToken, _ := auth.GenerateIDToken(customerID, auth.IDTokenClaims{Email: "customer@example.com"})
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		status int
+	}{
+		{
+			name:   "ok",
+			method: http.MethodGet,
+			token:  validToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "ok-post",
+			method: http.MethodPost,
+			token:  validToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "id-token",
+			method: http.MethodGet,
+			token:  idToken,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "no-token",
+			method: http.MethodGet,
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			targetURL := "/api/v1/auth/userinfo"
+
+			req := xtesting.NewTestingRequest(t, targetURL, testCase.method, nil)
+			if testCase.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCase.token))
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// verificationResendInterval is the minimum time between two verification emails to the same user
+const verificationResendInterval = time.Minute
+
+// sendVerificationEmail emails the user a link proving they own the address. The
+// link carries a signed token bound to the address, which stops working once the
+// address is verified.
+func sendVerificationEmail(tx *gorm.DB, user *models.User) error {
+	token, _, err := auth.NewToken(user.ID, user.Email, auth.TokenTypeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	if err := user.MarkVerificationSent(tx); err != nil {
+		return err
+	}
+
+	sendEmail(user.Email, "verify", map[string]interface{}{
+		"Subject":    "Verify your email address",
+		"UserName":   user.FirstName,
+		"VerifyLink": fmt.Sprintf("%s/verify-email?token=%s", getFrontendURL(), url.QueryEscape(token)),
+	})
+
+	return nil
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmail
+//
+//	@Id				VerifyEmail
+//	@Summary		Verify email address
+//	@Description	Confirm ownership of the email address with the token from the verification email. Each token can only be used once.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		VerifyEmailRequest	true	"Verification token"
+//	@Success		200		{object}	object{message=string}
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/verify-email [post]
+func VerifyEmail(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	claims, err := auth.ValidateToken(req.Token, auth.TokenTypeEmailVerification)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	user, err := models.GetUser(tx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+			return
+		}
+		_ = c.Error(err)
+		return
+	}
+
+	// The token is only good for the address it was sent to and only until it's verified
+	if user.Email != claims.Email || user.IsEmailVerified() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	if err := user.MarkEmailVerified(tx); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResendVerificationEmail
+//
+//	@Id				ResendVerificationEmail
+//	@Summary		Resend verification email
+//	@Description	Send a new verification email to an unverified account. The response is the same whether or not the account exists, and emails to the same account are throttled.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		ResendVerificationRequest	true	"Email address"
+//	@Success		202		{object}	object{message=string}
+//	@Failure		400		{object}	middleware.HttpError
+//	@Failure		413		{object}	middleware.HttpError
+//	@Failure		429		{object}	middleware.HttpError
+//	@Failure		500		{object}	middleware.HttpError
+//	@Failure		503		{object}	middleware.HttpError
+//	@Router			/verify-email/resend [post]
+func ResendVerificationEmail(c *gin.Context) {
+	tx := middleware.GetContextTransaction(c)
+
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := models.GetUserByEmail(tx, req.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = c.Error(err)
+		return
+	}
+
+	if err == nil && user.Active && !user.IsEmailVerified() && !user.VerificationSentWithin(verificationResendInterval) {
+		if err := sendVerificationEmail(tx, &user); err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists and is not verified yet, a verification email has been sent"})
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// markUnverified resets the email verification of a fixture user
+func markUnverified(t *testing.T, db *gorm.DB, userID uuid.UUID) {
+	err := db.Model(&models.User{}).Where("id = ?", userID).Update("email_verified_at", nil).Error
+	require.NoError(t, err)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	verificationToken, _, _ := auth.NewToken(customerID, "customer@example.com", auth.TokenTypeEmailVerification)
+	otherEmailToken, _, _ := auth.NewToken(customerID, "old@example.com", auth.TokenTypeEmailVerification)
+	accessToken, _ := auth.GenerateToken(customerID, "customer@example.com")
+
+	tests := []struct {
+		name       string
+		body       any
+		unverified bool
+		status     int
+	}{
+		{
+			name:       "ok",
+			body:       VerifyEmailRequest{Token: verificationToken},
+			unverified: true,
+			status:     http.StatusOK,
+		},
+		{
+			name:   "already-verified",
+			body:   VerifyEmailRequest{Token: verificationToken},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:       "other-email",
+			body:       VerifyEmailRequest{Token: otherEmailToken},
+			unverified: true,
+			status:     http.StatusBadRequest,
+		},
+		{
+			name:       "access-token",
+			body:       VerifyEmailRequest{Token: accessToken},
+			unverified: true,
+			status:     http.StatusBadRequest,
+		},
+		{
+			name:   "no-body",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			if testCase.unverified {
+				markUnverified(t, db, customerID)
+			}
+
+			targetURL := "/api/v1/auth/verify-email"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+		})
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+
+	tests := []struct {
+		name       string
+		body       any
+		unverified bool
+		status     int
+	}{
+		{
+			name:       "ok",
+			body:       ResendVerificationRequest{Email: "customer@example.com"},
+			unverified: true,
+			status:     http.StatusAccepted,
+		},
+		{
+			name:   "already-verified",
+			body:   ResendVerificationRequest{Email: "customer@example.com"},
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "unknown-email",
+			body:   ResendVerificationRequest{Email: "nonexistent@example.com"},
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "validation-error-email",
+			body:   ResendVerificationRequest{Email: "invalid-email"},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			if testCase.unverified {
+				markUnverified(t, db, customerID)
+			}
+
+			targetURL := "/api/v1/auth/verify-email/resend"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			xtesting.AssertGoldenJSON(t, w)
+		})
+	}
+}
+
+func TestLoginEmailVerificationRequired(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	t.Setenv("REQUIRE_EMAIL_VERIFICATION", "true")
+
+	customerID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+
+	tests := []struct {
+		name       string
+		body       LoginRequest
+		unverified bool
+		status     int
+	}{
+		{
+			name:   "ok",
+			body:   LoginRequest{Email: "customer@example.com", Password: "customer123"},
+			status: http.StatusOK,
+		},
+		{
+			name:       "unverified",
+			body:       LoginRequest{Email: "customer@example.com", Password: "customer123"},
+			unverified: true,
+			status:     http.StatusForbidden,
+		},
+		{
+			name:       "unverified-wrong-password",
+			body:       LoginRequest{Email: "customer@example.com", Password: "wrongpassword"},
+			unverified: true,
+			status:     http.StatusUnauthorized,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := fixtures.Load()
+			assert.NoError(t, err)
+
+			if testCase.unverified {
+				markUnverified(t, db, customerID)
+			}
+
+			targetURL := "/api/v1/auth/login"
+
+			req := xtesting.NewTestingRequest(t, targetURL, http.MethodPost, testCase.body)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.status, w.Code)
+			if testCase.status != http.StatusOK {
+				xtesting.AssertGoldenJSON(t, w)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS
+//
+//	@Id				JWKS
+//	@Summary		JSON Web Key Set
+//	@Description	Public keys other services can use to verify access tokens locally. Empty when tokens are signed with a shared secret.
+//	@Tags			discovery
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKS
+//	@Failure		500	{object}	middleware.HttpError
+//	@Router			/.well-known/jwks.json [get]
+func JWKS(c *gin.Context) {
+	jwks, err := auth.PublicJWKS()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, jwks)
+}
+
+// AuthConfiguration
+//
+//	@Id				AuthConfiguration
+//	@Summary		Service metadata
+//	@Description	Where to find the signing keys, user info and introspection of this service, and the audience of ID tokens. This is not OpenID Connect discovery, tokens are only issued by the login endpoints.
+//	@Tags			discovery
+//	@Produce		json
+//	@Success		200	{object}	auth.ServiceMetadata
+//	@Failure		500	{object}	middleware.HttpError
+//	@Router			/.well-known/auth-configuration [get]
+func AuthConfiguration(c *gin.Context) {
+	metadata, err := auth.NewServiceMetadata()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, metadata)
+}
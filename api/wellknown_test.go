@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/PRPO-skupina-02/common/xtesting"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWKS(t *testing.T) {
+	db, _ := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	targetURL := "/api/v1/auth/.well-known/jwks.json"
+
+	req := xtesting.NewTestingRequest(t, targetURL, http.MethodGet, nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
+
+func TestAuthConfiguration(t *testing.T) {
+	db, _ := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+	r := TestingRouter(t, db)
+
+	targetURL := "/api/v1/auth/.well-known/auth-configuration"
+
+	req := xtesting.NewTestingRequest(t, targetURL, http.MethodGet, nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	xtesting.AssertGoldenJSON(t, w)
+}
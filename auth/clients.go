@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PRPO-skupina-02/common/config"
+)
+
+// MinClientSecretLength is the minimum length of a client secret outside development
+const MinClientSecretLength = 32
+
+// getIntrospectionClients parses INTROSPECTION_CLIENTS, a comma separated list of
+// client_id:client_secret pairs of the resource servers allowed to introspect tokens
+func getIntrospectionClients() (map[string]string, error) {
+	clients := map[string]string{}
+
+	value := config.GetEnvDefault("INTROSPECTION_CLIENTS", "")
+	if value == "" {
+		return clients, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		clientID, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || clientID == "" || secret == "" {
+			return nil, errors.New("INTROSPECTION_CLIENTS must be a comma separated list of client_id:client_secret pairs")
+		}
+		if _, exists := clients[clientID]; exists {
+			return nil, fmt.Errorf("INTROSPECTION_CLIENTS lists client %q more than once", clientID)
+		}
+		clients[clientID] = secret
+	}
+
+	return clients, nil
+}
+
+// AuthenticateClient checks the credentials of a client calling the introspection endpoint
+func AuthenticateClient(clientID, secret string) bool {
+	clients, err := getIntrospectionClients()
+	if err != nil {
+		return false
+	}
+
+	// Digests are compared so the comparison doesn't leak the length of the secret,
+	// and it's done for unknown clients as well so they can't be told apart by timing
+	expected, ok := clients[clientID]
+	expectedSum := sha256.Sum256([]byte(expected))
+	sum := sha256.Sum256([]byte(secret))
+
+	return subtle.ConstantTimeCompare(expectedSum[:], sum[:]) == 1 && ok
+}
+
+// ValidateClientConfig reports problems with the configured introspection clients.
+// Outside development their secrets have to be long enough.
+func ValidateClientConfig(development bool) error {
+	clients, err := getIntrospectionClients()
+	if err != nil {
+		return err
+	}
+
+	if development {
+		return nil
+	}
+
+	var errs []error
+	for clientID, secret := range clients {
+		if len(secret) < MinClientSecretLength {
+			errs = append(errs, fmt.Errorf("INTROSPECTION_CLIENTS secret of client %q must be at least %d bytes long", clientID, MinClientSecretLength))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticateClient(t *testing.T) {
+	t.Setenv("INTROSPECTION_CLIENTS", "gateway:gateway-secret, billing:billing-secret")
+
+	assert.True(t, AuthenticateClient("gateway", "gateway-secret"))
+	assert.True(t, AuthenticateClient("billing", "billing-secret"))
+	assert.False(t, AuthenticateClient("gateway", "billing-secret"))
+	assert.False(t, AuthenticateClient("unknown", ""))
+}
+
+func TestValidateClientConfig(t *testing.T) {
+	t.Setenv("INTROSPECTION_CLIENTS", "gateway:short")
+	assert.NoError(t, ValidateClientConfig(true))
+	assert.Error(t, ValidateClientConfig(false))
+
+	t.Setenv("INTROSPECTION_CLIENTS", "gateway")
+	assert.Error(t, ValidateClientConfig(true))
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/PRPO-skupina-02/common/config"
+)
+
+const (
+	DefaultAccessTokenTTL       = 24 * time.Hour
+	DefaultRefreshTokenTTL      = 7 * 24 * time.Hour
+	DefaultEmailVerificationTTL = 24 * time.Hour
+	DefaultPasswordResetTTL     = time.Hour
+	DefaultMFAChallengeTTL      = 5 * time.Minute
+	DefaultEmailLoginTTL        = 10 * time.Minute
+	DefaultRecentAuthMaxAge     = 5 * time.Minute
+	DefaultIssuer               = "http://localhost:8080/api/v1/auth"
+	DefaultAudience             = "prpo"
+	DefaultClientID             = "prpo-frontend"
+
+	RegistrationResponseDetailed = "detailed"
+	RegistrationResponseGeneric  = "generic"
+)
+
+// getDurationEnv parses the env var as a duration such as "15m" or "720h". Invalid
+// values fall back to the default, they are reported by ValidateTokenConfig.
+func getDurationEnv(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(config.GetEnvDefault(key, def.String()))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+func GetAccessTokenTTL() time.Duration {
+	return getDurationEnv("ACCESS_TOKEN_TTL", DefaultAccessTokenTTL)
+}
+
+func GetRefreshTokenTTL() time.Duration {
+	return getDurationEnv("REFRESH_TOKEN_TTL", DefaultRefreshTokenTTL)
+}
+
+func GetEmailVerificationTTL() time.Duration {
+	return getDurationEnv("EMAIL_VERIFICATION_TTL", DefaultEmailVerificationTTL)
+}
+
+func GetPasswordResetTTL() time.Duration {
+	return getDurationEnv("PASSWORD_RESET_TTL", DefaultPasswordResetTTL)
+}
+
+// GetMFAChallengeTTL returns how long a user has to enter their MFA code after
+// their password was accepted
+func GetMFAChallengeTTL() time.Duration {
+	return getDurationEnv("MFA_CHALLENGE_TTL", DefaultMFAChallengeTTL)
+}
+
+// GetEmailLoginTTL returns how long an emailed login code or link can be used
+func GetEmailLoginTTL() time.Duration {
+	return getDurationEnv("EMAIL_LOGIN_TTL", DefaultEmailLoginTTL)
+}
+
+// GetRecentAuthMaxAge returns how long after logging in or reauthenticating the
+// user can do sensitive operations such as changing their password
+func GetRecentAuthMaxAge() time.Duration {
+	return getDurationEnv("REAUTH_MAX_AGE", DefaultRecentAuthMaxAge)
+}
+
+// RequireEmailVerification reports whether users have to verify their email
+// address before they can log in
+func RequireEmailVerification() bool {
+	required, err := strconv.ParseBool(config.GetEnvDefault("REQUIRE_EMAIL_VERIFICATION", "false"))
+	return err == nil && required
+}
+
+// GetRegistrationResponse returns how registration responds. Detailed responses
+// return the new user, or a conflict if the address is taken. Generic responses
+// are the same either way, so they don't reveal which addresses have accounts.
+func GetRegistrationResponse() string {
+	return config.GetEnvDefault("REGISTRATION_RESPONSE", RegistrationResponseDetailed)
+}
+
+// GetIssuer returns the iss claim of issued tokens, the public URL of the service
+func GetIssuer() string {
+	return config.GetEnvDefault("JWT_ISSUER", DefaultIssuer)
+}
+
+// GetAudience returns the aud claim of issued tokens, identifying the services
+// the tokens are meant for
+func GetAudience() string {
+	return config.GetEnvDefault("JWT_AUDIENCE", DefaultAudience)
+}
+
+// GetClientID returns the client_id of the frontend, the aud claim of ID tokens.
+// It has to differ from the audience of access tokens, so services don't accept
+// ID tokens in their place.
+func GetClientID() string {
+	return config.GetEnvDefault("CLIENT_ID", DefaultClientID)
+}
+
+// ValidateTokenConfig reports every problem with the configured token lifetimes
+// and claims
+func ValidateTokenConfig() error {
+	var errs []error
+
+	for _, key := range []string{"ACCESS_TOKEN_TTL", "REFRESH_TOKEN_TTL", "EMAIL_VERIFICATION_TTL", "PASSWORD_RESET_TTL", "MFA_CHALLENGE_TTL", "EMAIL_LOGIN_TTL", "REAUTH_MAX_AGE"} {
+		value := config.GetEnvDefault(key, "")
+		if value == "" {
+			continue
+		}
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be a positive duration such as 15m or 24h", key))
+		}
+	}
+
+	if GetAccessTokenTTL() > GetRefreshTokenTTL() {
+		errs = append(errs, errors.New("ACCESS_TOKEN_TTL must not be longer than REFRESH_TOKEN_TTL"))
+	}
+
+	if _, err := strconv.ParseBool(config.GetEnvDefault("REQUIRE_EMAIL_VERIFICATION", "false")); err != nil {
+		errs = append(errs, errors.New("REQUIRE_EMAIL_VERIFICATION must be true or false"))
+	}
+
+	registrationResponse := GetRegistrationResponse()
+	if registrationResponse != RegistrationResponseDetailed && registrationResponse != RegistrationResponseGeneric {
+		errs = append(errs, fmt.Errorf("REGISTRATION_RESPONSE must be %s or %s", RegistrationResponseDetailed, RegistrationResponseGeneric))
+	}
+
+	if GetIssuer() == "" {
+		errs = append(errs, errors.New("JWT_ISSUER must not be empty"))
+	}
+	if GetAudience() == "" {
+		errs = append(errs, errors.New("JWT_AUDIENCE must not be empty"))
+	}
+	if GetClientID() == "" {
+		errs = append(errs, errors.New("CLIENT_ID must not be empty"))
+	} else if GetClientID() == GetAudience() {
+		errs = append(errs, errors.New("CLIENT_ID must differ from JWT_AUDIENCE"))
+	}
+
+	return errors.Join(errs...)
+}
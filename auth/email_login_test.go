@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateEmailLoginCode(t *testing.T) {
+	seen := map[string]bool{}
+	for range 100 {
+		code, err := GenerateEmailLoginCode()
+		require.NoError(t, err)
+		assert.Regexp(t, `^[0-9]{6}$`, code)
+		seen[code] = true
+	}
+	assert.Greater(t, len(seen), 90)
+}
+
+func TestHashEmailLoginCode(t *testing.T) {
+	assert.Equal(t, HashEmailLoginCode("012345"), HashEmailLoginCode(" 012 345 "))
+	assert.Equal(t, HashEmailLoginCode("012345"), HashEmailLoginCode("012-345"))
+	assert.NotEqual(t, HashEmailLoginCode("012345"), HashEmailLoginCode("012346"))
+}
+
+func TestHashEmailLoginCodeKeyed(t *testing.T) {
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "")
+	t.Setenv("JWT_SECRET", "a-long-enough-secret-for-production-use")
+	withJWTSecret := HashEmailLoginCode("012345")
+
+	// A leaked digest can't be looked up among the million codes without the secret
+	assert.NotEqual(t, HashToken("012345"), withJWTSecret)
+
+	t.Setenv("JWT_SECRET", "another-long-enough-secret-for-production")
+	assert.NotEqual(t, withJWTSecret, HashEmailLoginCode("012345"))
+
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "an-encryption-key-of-at-least-32-bytes")
+	withEncryptionKey := HashEmailLoginCode("012345")
+	t.Setenv("JWT_SECRET", "a-long-enough-secret-for-production-use")
+	assert.Equal(t, withEncryptionKey, HashEmailLoginCode("012345"))
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// JWK is the public part of a signing key as described in RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// NewJWK describes the public key of an asymmetric signing key
+func NewJWK(key *SigningKey) (JWK, error) {
+	jwk, err := publicJWK(key)
+	if err != nil {
+		return JWK{}, err
+	}
+	jwk.Kid = key.ID
+
+	return jwk, nil
+}
+
+// publicJWK describes the public key without a key ID, which is derived from it
+func publicJWK(key *SigningKey) (JWK, error) {
+	jwk := JWK{
+		Use: "sig",
+		Alg: key.Method.Alg(),
+	}
+
+	switch publicKey := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBase64URL(publicKey.N.Bytes())
+		jwk.E = encodeBase64URL(big.NewInt(int64(publicKey.E)).Bytes())
+
+	case *ecdsa.PublicKey:
+		ecdhKey, err := publicKey.ECDH()
+		if err != nil {
+			return JWK{}, err
+		}
+		// Uncompressed point encoding: 0x04 || X || Y
+		point := ecdhKey.Bytes()
+		size := (len(point) - 1) / 2
+
+		jwk.Kty = "EC"
+		jwk.Crv = publicKey.Curve.Params().Name
+		jwk.X = encodeBase64URL(point[1 : 1+size])
+		jwk.Y = encodeBase64URL(point[1+size:])
+
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeBase64URL(publicKey)
+
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", key.PublicKey)
+	}
+
+	return jwk, nil
+}
+
+// Thumbprint computes the RFC 7638 thumbprint of the key, which is used as its key ID
+func (k JWK) Thumbprint() (string, error) {
+	// The required members in lexicographic order, as mandated by the RFC
+	var members any
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+
+	encoded, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(encoded)
+	return encodeBase64URL(sum[:]), nil
+}
+
+// PublicJWKS returns the keys other services can use to verify tokens locally,
+// including retired keys whose tokens haven't expired yet. Keys that are shared
+// secrets are never published.
+func PublicJWKS() (JWKS, error) {
+	jwks := JWKS{Keys: []JWK{}}
+
+	keys, err := getKeyring().Keys()
+	if err != nil {
+		return jwks, err
+	}
+
+	for _, key := range keys {
+		if key.IsSymmetric() {
+			continue
+		}
+
+		jwk, err := NewJWK(key)
+		if err != nil {
+			return jwks, err
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+
+	return jwks, nil
+}
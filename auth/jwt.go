@@ -1,7 +1,11 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/PRPO-skupina-02/common/config"
@@ -10,51 +14,171 @@ import (
 )
 
 var (
-	ErrInvalidToken = errors.New("invalid token")
-	ErrExpiredToken = errors.New("token has expired")
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrExpiredToken   = errors.New("token has expired")
+	ErrWrongTokenType = errors.New("wrong token type")
+	ErrRevokedToken   = errors.New("token has been revoked")
+)
+
+// TokenType describes what a token may be used for
+type TokenType string
+
+const (
+	TokenTypeAccess            TokenType = "access"
+	TokenTypeRefresh           TokenType = "refresh"
+	TokenTypeEmailVerification TokenType = "email_verification"
+	// TokenTypeMFAChallenge is issued after the password of a user with MFA was
+	// checked, to be exchanged for tokens together with an MFA code
+	TokenTypeMFAChallenge TokenType = "mfa_challenge"
+	// TokenTypeID marks ID tokens, which describe the user to the client and are
+	// never accepted by ValidateToken
+	TokenTypeID TokenType = "id"
+)
+
+// Authentication methods of the amr claim, see RFC 8176
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRHardwareKey = "hwk"
+	// AMREmail is a code or link sent by email, which RFC 8176 has no value for
+	AMREmail = "email"
+	// AMRMultiFactor is added once a second factor was verified
+	AMRMultiFactor = "mfa"
 )
 
 type Claims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	UserID   uuid.UUID `json:"user_id"`
+	Email    string    `json:"email"`
+	TokenUse TokenType `json:"token_use"`
+	// AMR lists the methods the user authenticated with, and AuthTime when. Refresh
+	// tokens carry both over to the tokens issued when they are rotated.
+	AMR      []string         `json:"amr,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// HasMFA reports whether the user logged in with a second factor
+func (c Claims) HasMFA() bool {
+	return slices.Contains(c.AMR, AMRMultiFactor)
+}
+
+// AuthenticatedAt returns when the user logged in or last reauthenticated, or the
+// zero time for tokens without the auth_time claim
+func (c Claims) AuthenticatedAt() time.Time {
+	if c.AuthTime == nil {
+		return time.Time{}
+	}
+	return c.AuthTime.Time
+}
+
+// TokenOption sets optional claims of a token issued by NewToken
+type TokenOption func(*Claims)
+
+// WithAMR records the methods the user authenticated with
+func WithAMR(amr ...string) TokenOption {
+	return func(c *Claims) {
+		c.AMR = amr
+	}
+}
+
+// WithAuthTime records when the user authenticated. The zero time leaves the
+// claim out.
+func WithAuthTime(authTime time.Time) TokenOption {
+	return func(c *Claims) {
+		if !authTime.IsZero() {
+			c.AuthTime = jwt.NewNumericDate(authTime)
+		}
+	}
+}
+
+// DevJWTSecret is the secret used when JWT_SECRET isn't set. It is public, so
+// ValidateSigningConfig rejects it outside development.
+const DevJWTSecret = "dev-secret-key-change-in-production"
+
+// MinJWTSecretLength is the minimum length of JWT_SECRET in bytes, matching the
+// output size of SHA-256 used by HS256
+const MinJWTSecretLength = 32
+
 func GetJWTSecret() string {
-	return config.GetEnvDefault("JWT_SECRET", "dev-secret-key-change-in-production")
+	return config.GetEnvDefault("JWT_SECRET", DevJWTSecret)
 }
 
-func GenerateToken(userID uuid.UUID, email string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+func GenerateToken(userID uuid.UUID, email string, opts ...TokenOption) (string, error) {
+	token, _, err := NewToken(userID, email, TokenTypeAccess, opts...)
+	return token, err
+}
+
+func GenerateRefreshToken(userID uuid.UUID, email string, opts ...TokenOption) (string, error) {
+	token, _, err := NewToken(userID, email, TokenTypeRefresh, opts...)
+	return token, err
+}
+
+// NewToken issues a signed token of the given type and returns it together with
+// its claims, so callers can tell when it expires
+func NewToken(userID uuid.UUID, email string, tokenType TokenType, opts ...TokenOption) (string, *Claims, error) {
+	var ttl time.Duration
+	switch tokenType {
+	case TokenTypeRefresh:
+		ttl = GetRefreshTokenTTL()
+	case TokenTypeEmailVerification:
+		ttl = GetEmailVerificationTTL()
+	case TokenTypeMFAChallenge:
+		ttl = GetMFAChallengeTTL()
+	default:
+		ttl = GetAccessTokenTTL()
+	}
+
+	now := time.Now()
 
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:   userID,
+		Email:    email,
+		TokenUse: tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			Issuer:    GetIssuer(),
+			Audience:  jwt.ClaimStrings{GetAudience()},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
+	for _, opt := range opts {
+		opt(claims)
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(GetJWTSecret()))
+	tokenString, err := signToken(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tokenString, claims, nil
+}
+
+// signToken signs the claims with the active key and names the key in the kid header
+func signToken(claims jwt.Claims) (string, error) {
+	key, err := getKeyring().Active()
 	if err != nil {
 		return "", err
 	}
 
-	return tokenString, nil
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
+// ValidateToken parses the token and checks that it was issued by this service for
+// the configured audience and for the given use, so a refresh token can't be
+// presented as an access token and vice versa. Tokens on the revocation list are
+// rejected.
+func ValidateToken(tokenString string, tokenType TokenType) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return []byte(GetJWTSecret()), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyFunc,
+		jwt.WithIssuer(GetIssuer()),
+		jwt.WithAudience(GetAudience()),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -67,27 +191,62 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, ErrInvalidToken
 	}
 
+	if tokenType == TokenTypeID || claims.TokenUse != tokenType {
+		return nil, ErrWrongTokenType
+	}
+
+	if revocations == nil {
+		return nil, ErrNoRevocationList
+	}
+	revoked, err := revocations.IsRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrRevokedToken
+	}
+
 	return claims, nil
 }
 
-func GenerateRefreshToken(userID uuid.UUID, email string) (string, error) {
-	expirationTime := time.Now().Add(7 * 24 * time.Hour) // 7 days
+// keyFunc picks the key a token is verified with by its kid header. Tokens issued
+// before keys had IDs are checked against the active key. Only the algorithm of
+// the key is accepted, so a token can't pick a weaker one or get its signature
+// checked with the public key used as an HMAC secret.
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	keys := getKeyring()
+
+	var key *SigningKey
+	var err error
+	if kid, ok := token.Header["kid"].(string); ok {
+		key, err = keys.Key(kid)
+	} else {
+		key, err = keys.Active()
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrInvalidToken
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(GetJWTSecret()))
-	if err != nil {
+	return key.verificationKey(), nil
+}
+
+// HashToken returns the digest under which a token is stored, so a database leak
+// doesn't hand out usable tokens
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateOpaqueToken returns a random token for single-use links sent by email.
+// Only its hash is stored, see HashToken.
+func GenerateOpaqueToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
 		return "", err
 	}
-
-	return tokenString, nil
+	return encodeBase64URL(token), nil
 }
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTokenLifetime(t *testing.T) {
+	t.Setenv("ACCESS_TOKEN_TTL", "15m")
+	t.Setenv("REFRESH_TOKEN_TTL", "720h")
+
+	_, claims, err := NewToken(uuid.New(), "customer@example.com", TokenTypeAccess)
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, claims.ExpiresAt.Sub(claims.IssuedAt.Time))
+
+	_, claims, err = NewToken(uuid.New(), "customer@example.com", TokenTypeRefresh)
+	require.NoError(t, err)
+	assert.Equal(t, 720*time.Hour, claims.ExpiresAt.Sub(claims.IssuedAt.Time))
+}
+
+func TestValidateTokenIssuerAndAudience(t *testing.T) {
+	setTestingRevocationList(t)
+
+	tests := []struct {
+		name     string
+		issuer   string
+		audience string
+		err      error
+	}{
+		{
+			name:     "ok",
+			issuer:   DefaultIssuer,
+			audience: DefaultAudience,
+		},
+		{
+			name:     "wrong-issuer",
+			issuer:   "https://attacker.example.com",
+			audience: DefaultAudience,
+			err:      ErrInvalidToken,
+		},
+		{
+			name:     "wrong-audience",
+			issuer:   DefaultIssuer,
+			audience: "other-project",
+			err:      ErrInvalidToken,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Setenv("JWT_ISSUER", testCase.issuer)
+			t.Setenv("JWT_AUDIENCE", testCase.audience)
+
+			token, err := GenerateToken(uuid.New(), "customer@example.com")
+			require.NoError(t, err)
+
+			t.Setenv("JWT_ISSUER", DefaultIssuer)
+			t.Setenv("JWT_AUDIENCE", DefaultAudience)
+
+			_, err = ValidateToken(token, TokenTypeAccess)
+			if testCase.err != nil {
+				assert.ErrorIs(t, err, testCase.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateTokenConfig(t *testing.T) {
+	t.Setenv("ACCESS_TOKEN_TTL", "forever")
+	t.Setenv("REFRESH_TOKEN_TTL", "-1h")
+	t.Setenv("JWT_ISSUER", "")
+	t.Setenv("REGISTRATION_RESPONSE", "silent")
+	t.Setenv("CLIENT_ID", GetAudience())
+
+	err := ValidateTokenConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ACCESS_TOKEN_TTL")
+	assert.Contains(t, err.Error(), "REFRESH_TOKEN_TTL")
+	assert.Contains(t, err.Error(), "JWT_ISSUER")
+	assert.Contains(t, err.Error(), "REGISTRATION_RESPONSE")
+	assert.Contains(t, err.Error(), "CLIENT_ID")
+}
+
+func TestNewTokenAMR(t *testing.T) {
+	setTestingRevocationList(t)
+
+	token, err := GenerateToken(uuid.New(), "customer@example.com", WithAMR(AMRPassword, AMROTP, AMRMultiFactor))
+	require.NoError(t, err)
+
+	claims, err := ValidateToken(token, TokenTypeAccess)
+	require.NoError(t, err)
+	assert.Equal(t, []string{AMRPassword, AMROTP, AMRMultiFactor}, claims.AMR)
+	assert.True(t, claims.HasMFA())
+
+	token, err = GenerateToken(uuid.New(), "customer@example.com", WithAMR(AMRPassword))
+	require.NoError(t, err)
+
+	claims, err = ValidateToken(token, TokenTypeAccess)
+	require.NoError(t, err)
+	assert.False(t, claims.HasMFA())
+}
+
+func TestNewTokenAuthTime(t *testing.T) {
+	setTestingRevocationList(t)
+
+	authTime := time.Now().Add(-time.Hour)
+
+	token, err := GenerateToken(uuid.New(), "customer@example.com", WithAuthTime(authTime))
+	require.NoError(t, err)
+
+	claims, err := ValidateToken(token, TokenTypeAccess)
+	require.NoError(t, err)
+	assert.Equal(t, authTime.Unix(), claims.AuthenticatedAt().Unix())
+
+	token, err = GenerateToken(uuid.New(), "customer@example.com", WithAuthTime(time.Time{}))
+	require.NoError(t, err)
+
+	claims, err = ValidateToken(token, TokenTypeAccess)
+	require.NoError(t, err)
+	assert.Nil(t, claims.AuthTime)
+	assert.True(t, claims.AuthenticatedAt().IsZero())
+}
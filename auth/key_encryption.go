@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+
+	"github.com/PRPO-skupina-02/common/config"
+	"github.com/google/uuid"
+)
+
+// MinSigningKeyEncryptionKeyLength is the minimum length of SIGNING_KEY_ENCRYPTION_KEY in bytes
+const MinSigningKeyEncryptionKeyLength = 32
+
+var ErrNoEncryptionKey = errors.New("stored secret is encrypted but SIGNING_KEY_ENCRYPTION_KEY is not set")
+
+// getSigningKeyCipher returns the cipher stored private keys and TOTP secrets are
+// encrypted with, or nil when SIGNING_KEY_ENCRYPTION_KEY isn't set
+func getSigningKeyCipher() (cipher.AEAD, error) {
+	secret := config.GetEnvDefault("SIGNING_KEY_ENCRYPTION_KEY", "")
+	if secret == "" {
+		return nil, nil
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// getEmailLoginCodeKey returns the secret emailed login codes are hashed with.
+// It is SIGNING_KEY_ENCRYPTION_KEY, or JWT_SECRET when that isn't set, which
+// ValidateSigningConfig only allows with HS256 outside development.
+func getEmailLoginCodeKey() []byte {
+	secret := config.GetEnvDefault("SIGNING_KEY_ENCRYPTION_KEY", "")
+	if secret == "" {
+		secret = GetJWTSecret()
+	}
+
+	// Derived, so the hashes don't reveal anything about the secret itself
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("email login code"))
+	return mac.Sum(nil)
+}
+
+// seal encrypts the plaintext with the configured encryption key, prefixed with
+// its nonce. It returns nil when no encryption key is configured.
+func seal(plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := getSigningKeyCipher()
+	if err != nil || aead == nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a ciphertext of seal
+func open(ciphertext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := getSigningKeyCipher()
+	if err != nil {
+		return nil, err
+	}
+	if aead == nil {
+		return nil, ErrNoEncryptionKey
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("encrypted secret is too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// sealStoredKey encrypts the private key before it is persisted, if an encryption
+// key is configured. The key ID is bound to the ciphertext, so a private key
+// can't be moved to another row.
+func sealStoredKey(key StoredKey) (StoredKey, error) {
+	sealed, err := seal(key.PrivateKey, []byte(key.ID))
+	if err != nil || sealed == nil {
+		return key, err
+	}
+
+	key.PrivateKey = sealed
+	key.Encrypted = true
+	return key, nil
+}
+
+// openStoredKey returns the private key of a persisted key in plaintext
+func openStoredKey(key StoredKey) ([]byte, error) {
+	if !key.Encrypted {
+		return key.PrivateKey, nil
+	}
+	return open(key.PrivateKey, []byte(key.ID))
+}
+
+// SealTOTPSecret encrypts the TOTP secret of the user before it is persisted, if
+// an encryption key is configured, and reports whether it did. The user ID is
+// bound to the ciphertext, so a secret can't be moved to another account.
+func SealTOTPSecret(userID uuid.UUID, secret string) (string, bool, error) {
+	sealed, err := seal([]byte(secret), userID[:])
+	if err != nil || sealed == nil {
+		return secret, false, err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), true, nil
+}
+
+// OpenTOTPSecret returns a TOTP secret stored by SealTOTPSecret in plaintext
+func OpenTOTPSecret(userID uuid.UUID, secret string, encrypted bool) (string, error) {
+	if !encrypted {
+		return secret, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(sealed, userID[:])
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
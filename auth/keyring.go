@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	keyringReloadInterval = time.Minute
+	// Minimum time between reloads triggered by tokens with an unknown kid, so
+	// made up key IDs can't be used to hammer the database
+	keyringMinReloadInterval = 10 * time.Second
+)
+
+var (
+	ErrUnknownKey  = errors.New("unknown signing key")
+	ErrNoKeyStore  = errors.New("signing keys can only be rotated with a key store")
+	ErrNoActiveKey = errors.New("no active signing key")
+)
+
+// StoredKey is a signing key as persisted in a KeyStore
+type StoredKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey []byte
+	// Encrypted is set when PrivateKey is encrypted with SIGNING_KEY_ENCRYPTION_KEY
+	Encrypted bool
+	Active    bool
+}
+
+// KeyStore persists signing keys so all replicas share them
+type KeyStore interface {
+	// LoadKeys returns the active key and the retired keys tokens may still be signed with
+	LoadKeys() ([]StoredKey, error)
+	// InitKey stores the key as the active one, unless there already is an active key
+	InitKey(key StoredKey) error
+	// PromoteKey stores the key as the active one. The previously active key is
+	// retired and kept for verification until retiredUntil.
+	PromoteKey(key StoredKey, retiredUntil time.Time) error
+}
+
+// Keyring holds the key new tokens are signed with and all keys tokens still in
+// circulation may have been signed with. Without a store it only holds the key
+// configured through the environment, which can't be rotated.
+type Keyring struct {
+	mu       sync.Mutex
+	store    KeyStore
+	active   *SigningKey
+	keys     map[string]*SigningKey
+	loadedAt time.Time
+	// envKeyWarned is the ID of the active key last warned about not matching the
+	// environment, so the warning isn't repeated on every reload
+	envKeyWarned string
+}
+
+func NewKeyring(store KeyStore) *Keyring {
+	return &Keyring{
+		store: store,
+		keys:  map[string]*SigningKey{},
+	}
+}
+
+// Load (re)loads the keys. With a store and no active key yet, the key configured
+// through the environment becomes the first active key. Later changes to the
+// environment don't replace it, only rotation does.
+func (k *Keyring) Load() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.load()
+}
+
+func (k *Keyring) load() error {
+	var storedKeys []StoredKey
+
+	if k.store == nil {
+		envKey, err := loadSigningKeyFromEnv()
+		if err != nil {
+			return err
+		}
+		envKey.Active = true
+		storedKeys = []StoredKey{envKey}
+	} else {
+		var err error
+		storedKeys, err = k.store.LoadKeys()
+		if err != nil {
+			return err
+		}
+
+		if !hasActiveKey(storedKeys) {
+			envKey, err := loadSigningKeyFromEnv()
+			if err != nil {
+				return err
+			}
+			sealedKey, err := sealStoredKey(envKey)
+			if err != nil {
+				return err
+			}
+			if err := k.store.InitKey(sealedKey); err != nil {
+				return err
+			}
+
+			storedKeys, err = k.store.LoadKeys()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	keys := map[string]*SigningKey{}
+	var active *SigningKey
+	for _, storedKey := range storedKeys {
+		privateKey, err := openStoredKey(storedKey)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", storedKey.ID, err)
+		}
+		key, err := ParseSigningKey(storedKey.Algorithm, privateKey)
+		if err != nil {
+			return err
+		}
+		key.ID = storedKey.ID
+
+		keys[key.ID] = key
+		if storedKey.Active {
+			active = key
+		}
+	}
+
+	if active == nil {
+		return ErrNoActiveKey
+	}
+
+	k.keys = keys
+	k.active = active
+	k.loadedAt = time.Now()
+
+	if k.store != nil {
+		k.warnEnvKeyMismatch()
+	}
+	return nil
+}
+
+// warnEnvKeyMismatch logs when the key configured through the environment isn't
+// the active stored key, because changing the env vars alone doesn't take effect
+func (k *Keyring) warnEnvKeyMismatch() {
+	if k.envKeyWarned == k.active.ID {
+		return
+	}
+
+	envKey, err := loadSigningKeyFromEnv()
+	if err != nil || envKey.ID == k.active.ID {
+		return
+	}
+
+	slog.Warn("The signing key configured through the environment is not the active key stored in the database and is ignored; rotate the signing key to replace it",
+		"active_kid", k.active.ID, "active_algorithm", k.active.Method.Alg(),
+		"env_kid", envKey.ID, "env_algorithm", envKey.Algorithm)
+	k.envKeyWarned = k.active.ID
+}
+
+func hasActiveKey(keys []StoredKey) bool {
+	for _, key := range keys {
+		if key.Active {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh reloads the keys when they are older than maxAge. If keys were loaded
+// before, a failed reload keeps them in use rather than failing every request.
+func (k *Keyring) refresh(maxAge time.Duration) error {
+	if k.active != nil && (k.store == nil || time.Since(k.loadedAt) < maxAge) {
+		return nil
+	}
+
+	err := k.load()
+	if err != nil && k.active != nil {
+		slog.Warn("Failed to reload signing keys", "error", err)
+		k.loadedAt = time.Now()
+		return nil
+	}
+	return err
+}
+
+// Active returns the key new tokens are signed with
+func (k *Keyring) Active() (*SigningKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.refresh(keyringReloadInterval); err != nil {
+		return nil, err
+	}
+	return k.active, nil
+}
+
+// Key returns the key with the given ID. A key promoted by another replica may
+// not be known yet, in which case the keys are reloaded.
+func (k *Keyring) Key(kid string) (*SigningKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.refresh(keyringReloadInterval); err != nil {
+		return nil, err
+	}
+
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+
+	if err := k.refresh(keyringMinReloadInterval); err != nil {
+		return nil, err
+	}
+
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// Keys returns every key tokens may currently be verified with
+func (k *Keyring) Keys() ([]*SigningKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.refresh(keyringReloadInterval); err != nil {
+		return nil, err
+	}
+
+	keys := make([]*SigningKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Rotate generates a new key and promotes it to the active key. The previous key
+// stays valid for verification until every token it signed has expired.
+func (k *Keyring) Rotate() (*SigningKey, error) {
+	if k.store == nil {
+		return nil, ErrNoKeyStore
+	}
+
+	storedKey, err := GenerateSigningKey(GetSigningAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+
+	storedKey, err = sealStoredKey(storedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	retiredUntil := time.Now().Add(max(GetAccessTokenTTL(), GetRefreshTokenTTL()))
+	if err := k.store.PromoteKey(storedKey, retiredUntil); err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+	return k.active, nil
+}
+
+var (
+	keyringMu sync.Mutex
+	keyring   *Keyring
+)
+
+// SetKeyring sets the keyring tokens are signed and verified with
+func SetKeyring(k *Keyring) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+
+	keyring = k
+}
+
+func getKeyring() *Keyring {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+
+	if keyring == nil {
+		keyring = NewKeyring(nil)
+	}
+	return keyring
+}
+
+// LoadKeys loads the signing keys. It is called at startup so a broken key
+// configuration is reported right away.
+func LoadKeys() error {
+	return getKeyring().Load()
+}
+
+// RotateSigningKey promotes a newly generated key to the active signing key
+func RotateSigningKey() (*SigningKey, error) {
+	return getKeyring().Rotate()
+}
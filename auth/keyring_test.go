@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryKeyStore struct {
+	mu   sync.Mutex
+	keys []StoredKey
+}
+
+func (s *memoryKeyStore) LoadKeys() ([]StoredKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]StoredKey{}, s.keys...), nil
+}
+
+func (s *memoryKeyStore) InitKey(key StoredKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if hasActiveKey(s.keys) {
+		return nil
+	}
+	key.Active = true
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+func (s *memoryKeyStore) PromoteKey(key StoredKey, retiredUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.keys {
+		s.keys[i].Active = false
+	}
+	key.Active = true
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+func TestKeyRotation(t *testing.T) {
+	setTestingRevocationList(t)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	t.Setenv("JWT_SIGNING_ALGORITHM", "ES256")
+	t.Setenv("JWT_PRIVATE_KEY", string(encodePrivateKeyPEM(t, ecKey)))
+
+	store := &memoryKeyStore{}
+	SetKeyring(NewKeyring(store))
+	defer SetKeyring(nil)
+
+	err = LoadKeys()
+	require.NoError(t, err)
+	require.Len(t, store.keys, 1)
+
+	oldToken, err := GenerateToken(uuid.New(), "customer@example.com")
+	require.NoError(t, err)
+
+	newKey, err := RotateSigningKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, store.keys[0].ID, newKey.ID)
+
+	newToken, err := GenerateToken(uuid.New(), "customer@example.com")
+	require.NoError(t, err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, newKey.ID, parsed.Header["kid"])
+
+	// Tokens signed with the retired key stay valid until they expire
+	_, err = ValidateToken(oldToken, TokenTypeAccess)
+	assert.NoError(t, err)
+	_, err = ValidateToken(newToken, TokenTypeAccess)
+	assert.NoError(t, err)
+
+	jwks, err := PublicJWKS()
+	require.NoError(t, err)
+	assert.Len(t, jwks.Keys, 2)
+}
+
+func TestValidateTokenUnknownKey(t *testing.T) {
+	setTestingRevocationList(t)
+
+	SetKeyring(NewKeyring(nil))
+	defer SetKeyring(nil)
+
+	claims := &Claims{UserID: uuid.New(), TokenUse: TokenTypeAccess}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "unknown"
+	tokenString, err := token.SignedString([]byte(GetJWTSecret()))
+	require.NoError(t, err)
+
+	_, err = ValidateToken(tokenString, TokenTypeAccess)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestKeyringEncryption(t *testing.T) {
+	t.Setenv("JWT_SIGNING_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", "a-long-enough-secret-for-production-use")
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "a-long-enough-encryption-key-for-signing-keys")
+
+	store := &memoryKeyStore{}
+	keyring := NewKeyring(store)
+	err := keyring.Load()
+	require.NoError(t, err)
+
+	require.Len(t, store.keys, 1)
+	assert.True(t, store.keys[0].Encrypted)
+	assert.NotContains(t, string(store.keys[0].PrivateKey), GetJWTSecret())
+
+	_, err = keyring.Rotate()
+	require.NoError(t, err)
+	require.Len(t, store.keys, 2)
+	assert.True(t, store.keys[1].Encrypted)
+
+	// Another replica with the same encryption key can use the stored keys
+	err = NewKeyring(store).Load()
+	assert.NoError(t, err)
+
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "")
+	err = NewKeyring(store).Load()
+	assert.ErrorIs(t, err, ErrNoEncryptionKey)
+
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "another-long-enough-encryption-key-for-keys")
+	err = NewKeyring(store).Load()
+	assert.Error(t, err)
+}
+
+func TestKeyringKeepsStoredKey(t *testing.T) {
+	t.Setenv("JWT_SIGNING_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", "a-long-enough-secret-for-production-use")
+
+	store := &memoryKeyStore{}
+	keyring := NewKeyring(store)
+	err := keyring.Load()
+	require.NoError(t, err)
+	stored, err := keyring.Active()
+	require.NoError(t, err)
+
+	// Changing the environment doesn't replace the stored key, it is only warned about
+	t.Setenv("JWT_SECRET", "another-long-enough-secret-for-production")
+	keyring = NewKeyring(store)
+	err = keyring.Load()
+	require.NoError(t, err)
+
+	active, err := keyring.Active()
+	require.NoError(t, err)
+	assert.Equal(t, stored.ID, active.ID)
+	assert.Equal(t, stored.ID, keyring.envKeyWarned)
+}
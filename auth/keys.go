@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/PRPO-skupina-02/common/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a key tokens are signed with, identified by the kid header of
+// the token. For symmetric algorithms the same secret is used for verification,
+// otherwise only the public key is needed and can be shared with other services.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+}
+
+func (k *SigningKey) IsSymmetric() bool {
+	_, ok := k.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// verificationKey returns the key jwt needs to verify a signature
+func (k *SigningKey) verificationKey() any {
+	if k.IsSymmetric() {
+		return k.PrivateKey
+	}
+	return k.PublicKey
+}
+
+// setID derives the key ID from the key itself, so the same key always gets the
+// same ID. Asymmetric keys use their JWK thumbprint.
+func (k *SigningKey) setID() error {
+	if k.IsSymmetric() {
+		sum := sha256.Sum256(k.PrivateKey.([]byte))
+		k.ID = encodeBase64URL(sum[:12])
+		return nil
+	}
+
+	jwk, err := publicJWK(k)
+	if err != nil {
+		return err
+	}
+	k.ID, err = jwk.Thumbprint()
+	return err
+}
+
+var SupportedSigningAlgorithms = []string{
+	jwt.SigningMethodHS256.Alg(),
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodES256.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+}
+
+func GetSigningAlgorithm() string {
+	return config.GetEnvDefault("JWT_SIGNING_ALGORITHM", jwt.SigningMethodHS256.Alg())
+}
+
+// getPrivateKeyPEM reads the private key either directly from JWT_PRIVATE_KEY or
+// from the file JWT_PRIVATE_KEY_FILE points to
+func getPrivateKeyPEM() ([]byte, error) {
+	if key := config.GetEnvDefault("JWT_PRIVATE_KEY", ""); key != "" {
+		return []byte(key), nil
+	}
+
+	path := config.GetEnvDefault("JWT_PRIVATE_KEY_FILE", "")
+	if path == "" {
+		return nil, errors.New("JWT_PRIVATE_KEY or JWT_PRIVATE_KEY_FILE must be set for asymmetric signing")
+	}
+
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	return key, nil
+}
+
+// ParseSigningKey builds a signing key for the algorithm from its stored form: the
+// raw secret for HS256 and a PEM encoded private key otherwise
+func ParseSigningKey(algorithm string, privateKey []byte) (*SigningKey, error) {
+	method := jwt.GetSigningMethod(algorithm)
+
+	var key *SigningKey
+	switch method {
+	case jwt.SigningMethodHS256:
+		if len(privateKey) == 0 {
+			return nil, errors.New("HS256 requires a non-empty secret")
+		}
+		key = &SigningKey{Method: method, PrivateKey: privateKey}
+
+	case jwt.SigningMethodRS256:
+		rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA private key: %w", err)
+		}
+		if rsaKey.N.BitLen() < 2048 {
+			return nil, errors.New("RSA private key must be at least 2048 bits")
+		}
+		key = &SigningKey{Method: method, PrivateKey: rsaKey, PublicKey: &rsaKey.PublicKey}
+
+	case jwt.SigningMethodES256:
+		ecKey, err := jwt.ParseECPrivateKeyFromPEM(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse EC private key: %w", err)
+		}
+		if ecKey.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 requires a P-256 private key")
+		}
+		key = &SigningKey{Method: method, PrivateKey: ecKey, PublicKey: &ecKey.PublicKey}
+
+	case jwt.SigningMethodEdDSA:
+		parsedKey, err := jwt.ParseEdPrivateKeyFromPEM(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Ed25519 private key: %w", err)
+		}
+		edKey, ok := parsedKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("EdDSA requires an Ed25519 private key")
+		}
+		key = &SigningKey{Method: method, PrivateKey: edKey, PublicKey: edKey.Public()}
+
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	if err := key.setID(); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// loadSigningKeyFromEnv builds the signing key from JWT_SIGNING_ALGORITHM and
+// either JWT_SECRET or the configured private key
+func loadSigningKeyFromEnv() (StoredKey, error) {
+	algorithm := GetSigningAlgorithm()
+
+	var privateKey []byte
+	if algorithm == jwt.SigningMethodHS256.Alg() {
+		privateKey = []byte(GetJWTSecret())
+	} else {
+		var err error
+		privateKey, err = getPrivateKeyPEM()
+		if err != nil {
+			return StoredKey{}, err
+		}
+	}
+
+	key, err := ParseSigningKey(algorithm, privateKey)
+	if err != nil {
+		return StoredKey{}, err
+	}
+
+	return StoredKey{
+		ID:         key.ID,
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+	}, nil
+}
+
+// ValidateSigningConfig reports every problem with the signing key configured
+// through the environment. Outside development the HS256 secret has to be set
+// explicitly and be long enough, and asymmetric keys need SIGNING_KEY_ENCRYPTION_KEY
+// to key emailed login codes with.
+func ValidateSigningConfig(development bool) error {
+	var errs []error
+
+	if key := os.Getenv("SIGNING_KEY_ENCRYPTION_KEY"); key != "" && len(key) < MinSigningKeyEncryptionKeyLength {
+		errs = append(errs, fmt.Errorf("SIGNING_KEY_ENCRYPTION_KEY must be at least %d bytes long", MinSigningKeyEncryptionKeyLength))
+	}
+
+	algorithm := GetSigningAlgorithm()
+	switch {
+	case !slices.Contains(SupportedSigningAlgorithms, algorithm):
+		errs = append(errs, fmt.Errorf("JWT_SIGNING_ALGORITHM must be one of %s", strings.Join(SupportedSigningAlgorithms, ", ")))
+	case algorithm != jwt.SigningMethodHS256.Alg():
+		if _, err := loadSigningKeyFromEnv(); err != nil {
+			errs = append(errs, fmt.Errorf("JWT_PRIVATE_KEY: %w", err))
+		}
+		// Without JWT_SECRET there is no other secret to key emailed login codes with
+		if !development && os.Getenv("SIGNING_KEY_ENCRYPTION_KEY") == "" {
+			errs = append(errs, fmt.Errorf("SIGNING_KEY_ENCRYPTION_KEY must be set with %s", algorithm))
+		}
+	default:
+		if err := validateJWTSecret(development); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateJWTSecret checks the HS256 secret set through JWT_SECRET, which may be
+// left unset in development to use DevJWTSecret
+func validateJWTSecret(development bool) error {
+	secret, ok := os.LookupEnv("JWT_SECRET")
+	switch {
+	case ok && secret == "":
+		return errors.New("JWT_SECRET must not be empty")
+	case development:
+		return nil
+	case !ok:
+		return errors.New("JWT_SECRET must be set")
+	}
+	if err := checkHS256Secret([]byte(secret)); err != nil {
+		return fmt.Errorf("JWT_SECRET %w", err)
+	}
+	return nil
+}
+
+// checkHS256Secret rejects secrets that are unsafe outside development
+func checkHS256Secret(secret []byte) error {
+	switch {
+	case string(secret) == DevJWTSecret:
+		return errors.New("must not be the development default")
+	case len(secret) < MinJWTSecretLength:
+		return fmt.Errorf("must be at least %d bytes long", MinJWTSecretLength)
+	}
+	return nil
+}
+
+// ValidateActiveKey checks the key tokens are actually signed with. Once a key is
+// stored it is used regardless of the environment, so a weak secret stored by an
+// earlier deployment is only caught here. It has to be called after LoadKeys.
+func ValidateActiveKey(development bool) error {
+	if development {
+		return nil
+	}
+
+	key, err := getKeyring().Active()
+	if err != nil {
+		return err
+	}
+	if !key.IsSymmetric() {
+		return nil
+	}
+
+	if err := checkHS256Secret(key.PrivateKey.([]byte)); err != nil {
+		return fmt.Errorf("active signing key %s %w, rotate the signing key", key.ID, err)
+	}
+	return nil
+}
+
+// GenerateSigningKey creates a new random key for the algorithm in its stored form
+func GenerateSigningKey(algorithm string) (StoredKey, error) {
+	var privateKey []byte
+
+	if algorithm == jwt.SigningMethodHS256.Alg() {
+		privateKey = make([]byte, 64)
+		if _, err := rand.Read(privateKey); err != nil {
+			return StoredKey{}, err
+		}
+	} else {
+		var generated crypto.PrivateKey
+		var err error
+
+		switch algorithm {
+		case jwt.SigningMethodRS256.Alg():
+			generated, err = rsa.GenerateKey(rand.Reader, 2048)
+		case jwt.SigningMethodES256.Alg():
+			generated, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		case jwt.SigningMethodEdDSA.Alg():
+			_, generated, err = ed25519.GenerateKey(rand.Reader)
+		default:
+			err = fmt.Errorf("unsupported signing algorithm %q", algorithm)
+		}
+		if err != nil {
+			return StoredKey{}, err
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(generated)
+		if err != nil {
+			return StoredKey{}, err
+		}
+		privateKey = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+
+	key, err := ParseSigningKey(algorithm, privateKey)
+	if err != nil {
+		return StoredKey{}, err
+	}
+
+	return StoredKey{
+		ID:         key.ID,
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+	}, nil
+}
@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePrivateKeyPEM(t *testing.T, key crypto.PrivateKey) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	setTestingRevocationList(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		algorithm string
+		key       crypto.PrivateKey
+		kty       string
+	}{
+		{
+			name:      "rs256",
+			algorithm: "RS256",
+			key:       rsaKey,
+			kty:       "RSA",
+		},
+		{
+			name:      "es256",
+			algorithm: "ES256",
+			key:       ecKey,
+			kty:       "EC",
+		},
+		{
+			name:      "eddsa",
+			algorithm: "EdDSA",
+			key:       edKey,
+			kty:       "OKP",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			keyFile := filepath.Join(t.TempDir(), "key.pem")
+			err := os.WriteFile(keyFile, encodePrivateKeyPEM(t, testCase.key), 0600)
+			require.NoError(t, err)
+
+			t.Setenv("JWT_SIGNING_ALGORITHM", testCase.algorithm)
+			t.Setenv("JWT_PRIVATE_KEY_FILE", keyFile)
+			SetKeyring(NewKeyring(nil))
+			defer SetKeyring(nil)
+			err = LoadKeys()
+			require.NoError(t, err)
+
+			token, err := GenerateToken(uuid.New(), "customer@example.com")
+			require.NoError(t, err)
+
+			_, err = ValidateToken(token, TokenTypeAccess)
+			assert.NoError(t, err)
+
+			jwks, err := PublicJWKS()
+			require.NoError(t, err)
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, testCase.kty, jwks.Keys[0].Kty)
+			assert.Equal(t, testCase.algorithm, jwks.Keys[0].Alg)
+			assert.NotEmpty(t, jwks.Keys[0].Kid)
+		})
+	}
+}
+
+func TestValidateTokenAlgorithmMismatch(t *testing.T) {
+	setTestingRevocationList(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	t.Setenv("JWT_SIGNING_ALGORITHM", "RS256")
+	t.Setenv("JWT_PRIVATE_KEY", string(encodePrivateKeyPEM(t, rsaKey)))
+	SetKeyring(NewKeyring(nil))
+	defer SetKeyring(nil)
+
+	// A token signed with HMAC using the public key as the secret must not be accepted
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})
+
+	claims := &Claims{UserID: uuid.New(), TokenUse: TokenTypeAccess}
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(publicKeyPEM)
+	require.NoError(t, err)
+
+	_, err = ValidateToken(forged, TokenTypeAccess)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestParseSigningKeyInvalid(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = ParseSigningKey("ES256", encodePrivateKeyPEM(t, ecKey))
+	assert.Error(t, err)
+
+	_, err = ParseSigningKey("RS256", []byte("not a key"))
+	assert.Error(t, err)
+
+	_, err = ParseSigningKey("none", nil)
+	assert.Error(t, err)
+}
+
+func TestValidateSigningConfig(t *testing.T) {
+	const unset = "<unset>"
+
+	tests := []struct {
+		name        string
+		algorithm   string
+		secret      string
+		development bool
+		valid       bool
+	}{
+		{
+			name:      "ok",
+			algorithm: "HS256",
+			secret:    "a-long-enough-secret-for-production-use",
+			valid:     true,
+		},
+		{
+			name:        "ok-development-default",
+			algorithm:   "HS256",
+			secret:      unset,
+			development: true,
+			valid:       true,
+		},
+		{
+			name:      "missing-secret",
+			algorithm: "HS256",
+			secret:    unset,
+		},
+		{
+			name:      "default-secret",
+			algorithm: "HS256",
+			secret:    DevJWTSecret,
+		},
+		{
+			name:      "short-secret",
+			algorithm: "HS256",
+			secret:    "too-short",
+		},
+		{
+			name:        "empty-secret",
+			algorithm:   "HS256",
+			secret:      "",
+			development: true,
+		},
+		{
+			name:        "missing-private-key",
+			algorithm:   "RS256",
+			secret:      unset,
+			development: true,
+		},
+		{
+			name:        "unsupported-algorithm",
+			algorithm:   "HS512",
+			secret:      unset,
+			development: true,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Setenv("JWT_SIGNING_ALGORITHM", testCase.algorithm)
+			t.Setenv("JWT_PRIVATE_KEY", "")
+			t.Setenv("JWT_SECRET", testCase.secret)
+			if testCase.secret == unset {
+				os.Unsetenv("JWT_SECRET")
+			}
+
+			err := ValidateSigningConfig(testCase.development)
+			if testCase.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateSigningConfigReportsAllProblems(t *testing.T) {
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "too-short")
+	t.Setenv("JWT_SIGNING_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", DevJWTSecret)
+
+	err := ValidateSigningConfig(false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SIGNING_KEY_ENCRYPTION_KEY")
+	assert.Contains(t, err.Error(), "JWT_SECRET")
+
+	t.Setenv("JWT_SIGNING_ALGORITHM", "HS512")
+
+	err = ValidateSigningConfig(false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SIGNING_KEY_ENCRYPTION_KEY")
+	assert.Contains(t, err.Error(), "JWT_SIGNING_ALGORITHM")
+}
+
+func TestValidateSigningConfigAsymmetric(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	t.Setenv("JWT_SIGNING_ALGORITHM", "ES256")
+	t.Setenv("JWT_PRIVATE_KEY", string(encodePrivateKeyPEM(t, ecKey)))
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "")
+
+	// Emailed login codes need a secret besides the private key
+	assert.NoError(t, ValidateSigningConfig(true))
+	err = ValidateSigningConfig(false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SIGNING_KEY_ENCRYPTION_KEY")
+
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "an-encryption-key-of-at-least-32-bytes")
+	assert.NoError(t, ValidateSigningConfig(false))
+}
+
+func TestValidateActiveKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		storedKey   string
+		development bool
+		valid       bool
+	}{
+		{
+			name:      "ok",
+			storedKey: "a-long-enough-secret-for-production-use",
+			valid:     true,
+		},
+		{
+			name:        "ok-development-default",
+			storedKey:   DevJWTSecret,
+			development: true,
+			valid:       true,
+		},
+		{
+			name:      "default-secret",
+			storedKey: DevJWTSecret,
+		},
+		{
+			name:      "short-secret",
+			storedKey: "too-short",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			// The environment is fixed, but the stored key from an earlier deployment is used
+			t.Setenv("JWT_SIGNING_ALGORITHM", "HS256")
+			t.Setenv("JWT_SECRET", "a-long-enough-secret-for-production-use")
+
+			key, err := ParseSigningKey("HS256", []byte(testCase.storedKey))
+			require.NoError(t, err)
+			store := &memoryKeyStore{keys: []StoredKey{{
+				ID:         key.ID,
+				Algorithm:  "HS256",
+				PrivateKey: []byte(testCase.storedKey),
+				Active:     true,
+			}}}
+
+			SetKeyring(NewKeyring(store))
+			defer SetKeyring(nil)
+
+			err = LoadKeys()
+			require.NoError(t, err)
+
+			err = ValidateActiveKey(testCase.development)
+			if testCase.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/PRPO-skupina-02/common/config"
+)
+
+const (
+	DefaultLoginLockoutThreshold   = 5
+	DefaultIPLoginLockoutThreshold = 20
+	DefaultLoginLockoutDuration    = 15 * time.Minute
+	DefaultLoginBackoffBase        = time.Second
+)
+
+// getIntEnv parses the env var as a positive integer. Invalid values fall back to
+// the default, they are reported by ValidateLockoutConfig.
+func getIntEnv(key string, def int) int {
+	value, err := strconv.Atoi(config.GetEnvDefault(key, strconv.Itoa(def)))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// GetLoginLockoutThreshold returns the number of consecutive failed logins after
+// which an account is locked
+func GetLoginLockoutThreshold() int {
+	return getIntEnv("LOGIN_LOCKOUT_THRESHOLD", DefaultLoginLockoutThreshold)
+}
+
+// GetIPLoginLockoutThreshold returns the number of failed logins from one IP
+// address after which the address is blocked, whichever accounts were tried
+func GetIPLoginLockoutThreshold() int {
+	return getIntEnv("IP_LOGIN_LOCKOUT_THRESHOLD", DefaultIPLoginLockoutThreshold)
+}
+
+// GetLoginLockoutDuration returns how long a locked account or blocked address
+// stays locked. Failures further apart than this are not counted together.
+func GetLoginLockoutDuration() time.Duration {
+	return getDurationEnv("LOGIN_LOCKOUT_DURATION", DefaultLoginLockoutDuration)
+}
+
+// GetLoginBackoffBase returns the delay after the first failed login, which
+// doubles with every further failure
+func GetLoginBackoffBase() time.Duration {
+	return getDurationEnv("LOGIN_BACKOFF_BASE", DefaultLoginBackoffBase)
+}
+
+// LoginBackoff returns how long an account can't be logged into after the given
+// number of consecutive failed logins, and whether that is a lockout. Below the
+// threshold the delay doubles with every failure, never exceeding the lockout.
+func LoginBackoff(failures int) (time.Duration, bool) {
+	lockout := GetLoginLockoutDuration()
+	if failures >= GetLoginLockoutThreshold() {
+		return lockout, true
+	}
+	if failures <= 0 {
+		return 0, false
+	}
+
+	delay := GetLoginBackoffBase()
+	for i := 1; i < failures && delay < lockout; i++ {
+		delay *= 2
+	}
+	return min(delay, lockout), false
+}
+
+// ValidateLockoutConfig reports every problem with the configured login lockout
+func ValidateLockoutConfig() error {
+	var errs []error
+
+	for _, key := range []string{"LOGIN_LOCKOUT_THRESHOLD", "IP_LOGIN_LOCKOUT_THRESHOLD"} {
+		value := config.GetEnvDefault(key, "")
+		if value == "" {
+			continue
+		}
+		threshold, err := strconv.Atoi(value)
+		if err != nil || threshold <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be a positive number", key))
+		}
+	}
+
+	for _, key := range []string{"LOGIN_LOCKOUT_DURATION", "LOGIN_BACKOFF_BASE"} {
+		value := config.GetEnvDefault(key, "")
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be a positive duration such as 1s or 15m", key))
+		}
+	}
+
+	if GetLoginBackoffBase() > GetLoginLockoutDuration() {
+		errs = append(errs, errors.New("LOGIN_BACKOFF_BASE must not be longer than LOGIN_LOCKOUT_DURATION"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "5")
+	t.Setenv("LOGIN_LOCKOUT_DURATION", "10s")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1s")
+
+	tests := []struct {
+		failures int
+		delay    time.Duration
+		lockout  bool
+	}{
+		{failures: 0, delay: 0},
+		{failures: 1, delay: time.Second},
+		{failures: 2, delay: 2 * time.Second},
+		{failures: 3, delay: 4 * time.Second},
+		{failures: 4, delay: 8 * time.Second},
+		{failures: 5, delay: 10 * time.Second, lockout: true},
+		{failures: 9, delay: 10 * time.Second, lockout: true},
+	}
+
+	for _, testCase := range tests {
+		delay, lockout := LoginBackoff(testCase.failures)
+		assert.Equal(t, testCase.delay, delay, "failures: %d", testCase.failures)
+		assert.Equal(t, testCase.lockout, lockout, "failures: %d", testCase.failures)
+	}
+
+	// The delay never exceeds the lockout, however high the threshold is set
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "50")
+	delay, lockout := LoginBackoff(40)
+	assert.Equal(t, 10*time.Second, delay)
+	assert.False(t, lockout)
+}
+
+func TestValidateLockoutConfig(t *testing.T) {
+	assert.NoError(t, ValidateLockoutConfig())
+
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "0")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1h")
+	err := ValidateLockoutConfig()
+	assert.ErrorContains(t, err, "LOGIN_LOCKOUT_THRESHOLD")
+	assert.ErrorContains(t, err, "LOGIN_BACKOFF_BASE must not be longer")
+}
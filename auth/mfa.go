@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/PRPO-skupina-02/common/config"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	DefaultTOTPIssuer = "CineCore"
+
+	// TOTPPeriod is how long a TOTP code is valid, in seconds
+	TOTPPeriod = 30
+	// totpSkew is how many periods a code may be off, to allow for clock drift
+	totpSkew = 1
+
+	// RecoveryCodeCount is how many recovery codes a user gets
+	RecoveryCodeCount = 10
+	// recoveryCodeLength is the number of base32 characters of a recovery code,
+	// 50 bits of randomness
+	recoveryCodeLength = 10
+
+	totpQRCodeSize = 256
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GetTOTPIssuer returns the name authenticator apps show next to the account
+func GetTOTPIssuer() string {
+	return config.GetEnvDefault("TOTP_ISSUER", DefaultTOTPIssuer)
+}
+
+// GenerateTOTPKey creates a new TOTP secret for the account
+func GenerateTOTPKey(accountName string) (*otp.Key, error) {
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      GetTOTPIssuer(),
+		AccountName: accountName,
+		Period:      TOTPPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+}
+
+// TOTPQRCode renders the otpauth URI of the key as a PNG data URI, for
+// authenticator apps to scan
+func TOTPQRCode(key *otp.Key) (string, error) {
+	img, err := key.Image(totpQRCodeSize, totpQRCodeSize)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// ValidateTOTP checks the code against the secret, accepting codes of the
+// neighbouring periods. It returns the time step the code belongs to, so callers
+// can refuse a code that was already used.
+func ValidateTOTP(secret string, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != otp.DigitsSix.Length() {
+		return 0, false
+	}
+
+	opts := totp.ValidateOpts{
+		Period:    TOTPPeriod,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	}
+
+	for skew := -totpSkew; skew <= totpSkew; skew++ {
+		at := now.Add(time.Duration(skew*TOTPPeriod) * time.Second)
+		expected, err := totp.GenerateCodeCustom(secret, at, opts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return at.Unix() / TOTPPeriod, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns a new set of one-time recovery codes, formatted
+// as two groups of five characters
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		random := make([]byte, 8)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(random)[:recoveryCodeLength])
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode makes a recovery code as typed by the user comparable to
+// the generated one, ignoring case, spaces and dashes
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// HashRecoveryCode returns the digest under which a recovery code is stored
+func HashRecoveryCode(code string) string {
+	return HashToken(NormalizeRecoveryCode(code))
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	key, err := GenerateTOTPKey("customer@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTOTPIssuer, key.Issuer())
+	assert.Equal(t, "customer@example.com", key.AccountName())
+
+	now := time.Unix(1_800_000_000, 0)
+	step := now.Unix() / TOTPPeriod
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		step   int64
+		ok     bool
+	}{
+		{name: "current", offset: 0, step: step, ok: true},
+		{name: "previous", offset: -TOTPPeriod * time.Second, step: step - 1, ok: true},
+		{name: "next", offset: TOTPPeriod * time.Second, step: step + 1, ok: true},
+		{name: "too-old", offset: -2 * TOTPPeriod * time.Second},
+		{name: "too-new", offset: 2 * TOTPPeriod * time.Second},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			code, err := totp.GenerateCode(key.Secret(), now.Add(testCase.offset))
+			require.NoError(t, err)
+
+			codeStep, ok := ValidateTOTP(key.Secret(), code, now)
+			assert.Equal(t, testCase.ok, ok)
+			assert.Equal(t, testCase.step, codeStep)
+		})
+	}
+
+	code, err := totp.GenerateCode(key.Secret(), now)
+	require.NoError(t, err)
+	_, ok := ValidateTOTP(key.Secret(), code[:3]+" "+code[3:], now)
+	assert.True(t, ok)
+
+	_, ok = ValidateTOTP(key.Secret(), "12345", now)
+	assert.False(t, ok)
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	require.NoError(t, err)
+	assert.Len(t, codes, RecoveryCodeCount)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+
+	typed := " " + strings.ToUpper(strings.ReplaceAll(codes[0], "-", " ")) + " "
+	assert.Equal(t, HashRecoveryCode(codes[0]), HashRecoveryCode(typed))
+	assert.NotEqual(t, HashRecoveryCode(codes[0]), HashRecoveryCode(codes[1]))
+}
+
+func TestTOTPSecretEncryption(t *testing.T) {
+	userID := uuid.New()
+
+	// Without an encryption key the secret is stored as it is
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "")
+	stored, encrypted, err := SealTOTPSecret(userID, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.False(t, encrypted)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", stored)
+
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "a-long-enough-encryption-key-for-signing-keys")
+	stored, encrypted, err = SealTOTPSecret(userID, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.True(t, encrypted)
+	assert.NotContains(t, stored, "JBSWY3DPEHPK3PXP")
+
+	secret, err := OpenTOTPSecret(userID, stored, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", secret)
+
+	// Secrets stored before the key was set stay readable
+	secret, err = OpenTOTPSecret(userID, "JBSWY3DPEHPK3PXP", false)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", secret)
+
+	// The secret can't be moved to another account
+	_, err = OpenTOTPSecret(uuid.New(), stored, encrypted)
+	assert.Error(t, err)
+
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "")
+	_, err = OpenTOTPSecret(userID, stored, encrypted)
+	assert.ErrorIs(t, err, ErrNoEncryptionKey)
+}
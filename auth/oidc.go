@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// IDTokenClaims are the claims of an OpenID Connect ID token, describing the
+// authenticated user to the client. It is addressed to the client rather than the
+// services and marked with its own token_use, so it can't be used to access the API.
+type IDTokenClaims struct {
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	GivenName     string    `json:"given_name,omitempty"`
+	FamilyName    string    `json:"family_name,omitempty"`
+	TokenUse      TokenType `json:"token_use"`
+	// AMR and AuthTime are those of the access token issued alongside it
+	AMR      []string         `json:"amr,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateIDToken issues an ID token for the user. The token use and registered
+// claims are filled in, it expires together with the access token issued
+// alongside it.
+func GenerateIDToken(userID uuid.UUID, claims IDTokenClaims) (string, error) {
+	now := time.Now()
+
+	claims.TokenUse = TokenTypeID
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Subject:   userID.String(),
+		Issuer:    GetIssuer(),
+		Audience:  jwt.ClaimStrings{GetClientID()},
+		ExpiresAt: jwt.NewNumericDate(now.Add(GetAccessTokenTTL())),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+
+	return signToken(&claims)
+}
+
+// ServiceMetadata tells clients and other services where to find the keys and
+// user info of this service, served under /.well-known/auth-configuration. It is
+// not OpenID Connect provider metadata: there is no authorization or token
+// endpoint, tokens are only issued by the login endpoints.
+type ServiceMetadata struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint            string   `json:"introspection_endpoint"`
+	IDTokenAudience                  string   `json:"id_token_audience"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+}
+
+// NewServiceMetadata describes this service. The endpoints are relative to the
+// issuer, which has to be the public URL of the API.
+func NewServiceMetadata() (ServiceMetadata, error) {
+	key, err := getKeyring().Active()
+	if err != nil {
+		return ServiceMetadata{}, err
+	}
+
+	issuer := GetIssuer()
+
+	return ServiceMetadata{
+		Issuer:                           issuer,
+		JWKSURI:                          issuer + "/.well-known/jwks.json",
+		UserinfoEndpoint:                 issuer + "/userinfo",
+		IntrospectionEndpoint:            issuer + "/introspect",
+		IDTokenAudience:                  GetClientID(),
+		IDTokenSigningAlgValuesSupported: []string{key.Method.Alg()},
+		ClaimsSupported:                  []string{"sub", "iss", "aud", "exp", "iat", "token_use", "auth_time", "amr", "email", "email_verified", "given_name", "family_name"},
+	}, nil
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIDToken(t *testing.T) {
+	setTestingRevocationList(t)
+
+	userID := uuid.New()
+	authTime := time.Now().Add(-time.Minute)
+
+	token, err := GenerateIDToken(userID, IDTokenClaims{
+		Email:      "customer@example.com",
+		GivenName:  "Customer",
+		FamilyName: "User",
+		AMR:        []string{AMRPassword},
+		AuthTime:   jwt.NewNumericDate(authTime),
+	})
+	require.NoError(t, err)
+
+	claims := &IDTokenClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, keyFunc, jwt.WithIssuer(GetIssuer()), jwt.WithAudience(GetClientID()))
+	require.NoError(t, err)
+	assert.Equal(t, userID.String(), claims.Subject)
+	assert.Equal(t, "customer@example.com", claims.Email)
+	assert.Equal(t, "Customer", claims.GivenName)
+	assert.Equal(t, TokenTypeID, claims.TokenUse)
+	assert.Equal(t, []string{AMRPassword}, claims.AMR)
+	assert.Equal(t, authTime.Unix(), claims.AuthTime.Unix())
+
+	// ID tokens describe the user to the client and don't grant access
+	_, err = ValidateToken(token, TokenTypeAccess)
+	assert.Error(t, err)
+	_, err = ValidateToken(token, TokenTypeID)
+	assert.Error(t, err)
+}
+
+func TestValidateTokenRejectsIDTokenUse(t *testing.T) {
+	setTestingRevocationList(t)
+
+	// Even with the audience of the services, the token use gives it away
+	t.Setenv("CLIENT_ID", GetAudience())
+
+	token, err := GenerateIDToken(uuid.New(), IDTokenClaims{Email: "customer@example.com"})
+	require.NoError(t, err)
+
+	_, err = ValidateToken(token, TokenTypeAccess)
+	assert.ErrorIs(t, err, ErrWrongTokenType)
+	_, err = ValidateToken(token, TokenTypeID)
+	assert.ErrorIs(t, err, ErrWrongTokenType)
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrRevocationsUnavailable is returned when the revoked tokens have never been synced
+var ErrRevocationsUnavailable = errors.New("revoked tokens are unavailable")
+
+// ErrNoRevocationList is returned by ValidateToken when SetRevocationList was never called
+var ErrNoRevocationList = errors.New("revocation list is not set")
+
+const (
+	revocationSyncInterval = 10 * time.Second
+	// Overlap between syncs, so revocations committed late by a slow transaction aren't missed
+	revocationSyncOverlap = time.Minute
+	// How long the last synced copy keeps being used while the backend can't be reached
+	revocationSyncGracePeriod = 2 * time.Minute
+)
+
+// RevocationBackend is the persistent store of revoked tokens shared by all replicas
+type RevocationBackend interface {
+	// RevokedTokensSince returns the IDs of unexpired tokens revoked after the given
+	// time, mapped to their expiry
+	RevokedTokensSince(since time.Time) (map[string]time.Time, error)
+}
+
+// RevocationList is an in-process copy of the revoked tokens. Entries live until the
+// revoked token would have expired anyway, and new revocations made by other replicas
+// are picked up at most every revocationSyncInterval, so checking a token normally
+// doesn't touch the database. If a sync fails, the last copy is used for up to
+// revocationSyncGracePeriod before checks start failing.
+type RevocationList struct {
+	mu       sync.Mutex
+	backend  RevocationBackend
+	tokens   map[string]time.Time
+	lastSync time.Time
+	nextSync time.Time
+	syncErr  error
+
+	// syncMu is held while the backend is queried, so only one sync runs at a time
+	// without blocking checks against the current copy
+	syncMu sync.Mutex
+}
+
+func NewRevocationList(backend RevocationBackend) *RevocationList {
+	return &RevocationList{
+		backend: backend,
+		tokens:  map[string]time.Time{},
+	}
+}
+
+// Add records a revocation made by this process. It must also be persisted
+// through the backend for other replicas to see it.
+func (l *RevocationList) Add(jti string, expiresAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens[jti] = expiresAt
+}
+
+func (l *RevocationList) IsRevoked(jti string) (bool, error) {
+	now := time.Now()
+	if err := l.refresh(now); err != nil {
+		return false, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expiresAt, ok := l.tokens[jti]
+	return ok && now.Before(expiresAt), nil
+}
+
+// refresh syncs the list when it's due and fails if the copy is too old to be
+// trusted. A sync already running elsewhere is only waited for when there is no
+// usable copy yet.
+func (l *RevocationList) refresh(now time.Time) error {
+	l.mu.Lock()
+	due := !now.Before(l.nextSync)
+	current := l.isCurrent(now)
+	l.mu.Unlock()
+
+	if due {
+		if current {
+			if !l.syncMu.TryLock() {
+				return nil
+			}
+		} else {
+			l.syncMu.Lock()
+		}
+		err := l.sync(now)
+		l.syncMu.Unlock()
+
+		if err != nil {
+			slog.Warn("Failed to sync revoked tokens", "error", err)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.isCurrent(now) {
+		if l.syncErr != nil {
+			return l.syncErr
+		}
+		return ErrRevocationsUnavailable
+	}
+	return nil
+}
+
+func (l *RevocationList) isCurrent(now time.Time) bool {
+	return !l.lastSync.IsZero() && now.Sub(l.lastSync) < revocationSyncGracePeriod
+}
+
+// sync fetches new revocations from the backend without holding mu, and merges
+// them into the list. It must be called with syncMu held.
+func (l *RevocationList) sync(now time.Time) error {
+	l.mu.Lock()
+	if now.Before(l.nextSync) {
+		// Another sync finished while this one waited
+		l.mu.Unlock()
+		return nil
+	}
+	since := time.Time{}
+	if !l.lastSync.IsZero() {
+		since = l.lastSync.Add(-revocationSyncOverlap)
+	}
+	l.mu.Unlock()
+
+	tokens, err := l.backend.RevokedTokensSince(since)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Failed syncs are retried on the same schedule, not on every check
+	l.nextSync = now.Add(revocationSyncInterval)
+	l.syncErr = err
+	if err != nil {
+		return err
+	}
+
+	for jti, expiresAt := range tokens {
+		l.tokens[jti] = expiresAt
+	}
+
+	for jti, expiresAt := range l.tokens {
+		if !now.Before(expiresAt) {
+			delete(l.tokens, jti)
+		}
+	}
+
+	l.lastSync = now
+	return nil
+}
+
+var revocations *RevocationList
+
+// SetRevocationList sets the list consulted by ValidateToken. It has to be set
+// before tokens are validated, ValidateToken rejects every token without it.
+func SetRevocationList(list *RevocationList) {
+	revocations = list
+}
+
+// RevokeToken makes ValidateToken reject the token with the given ID in this
+// process right away, without waiting for the next sync
+func RevokeToken(jti string, expiresAt time.Time) {
+	if revocations != nil {
+		revocations.Add(jti, expiresAt)
+	}
+}
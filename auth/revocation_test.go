@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testingRevocationBackend struct {
+	tokens map[string]time.Time
+	err    error
+	calls  int
+}
+
+func (b *testingRevocationBackend) RevokedTokensSince(since time.Time) (map[string]time.Time, error) {
+	b.calls++
+	return b.tokens, b.err
+}
+
+// setTestingRevocationList sets an empty revocation list for the test, which
+// ValidateToken needs
+func setTestingRevocationList(t *testing.T) {
+	SetRevocationList(NewRevocationList(&testingRevocationBackend{}))
+	t.Cleanup(func() { SetRevocationList(nil) })
+}
+
+func TestRevocationList(t *testing.T) {
+	backend := &testingRevocationBackend{
+		tokens: map[string]time.Time{
+			"revoked-elsewhere": time.Now().Add(time.Hour),
+			"expired":           time.Now().Add(-time.Hour),
+		},
+	}
+	list := NewRevocationList(backend)
+
+	tests := []struct {
+		name    string
+		jti     string
+		revoked bool
+	}{
+		{
+			name:    "revoked-elsewhere",
+			jti:     "revoked-elsewhere",
+			revoked: true,
+		},
+		{
+			name:    "expired",
+			jti:     "expired",
+			revoked: false,
+		},
+		{
+			name:    "not-revoked",
+			jti:     "not-revoked",
+			revoked: false,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			revoked, err := list.IsRevoked(testCase.jti)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.revoked, revoked)
+		})
+	}
+
+	// Checks between syncs are answered from memory
+	assert.Equal(t, 1, backend.calls)
+
+	list.Add("revoked-here", time.Now().Add(time.Hour))
+	revoked, err := list.IsRevoked("revoked-here")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestRevocationListSyncFailure(t *testing.T) {
+	backend := &testingRevocationBackend{
+		tokens: map[string]time.Time{
+			"revoked-elsewhere": time.Now().Add(time.Hour),
+		},
+	}
+	list := NewRevocationList(backend)
+
+	revoked, err := list.IsRevoked("revoked-elsewhere")
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	backend.err = errors.New("connection refused")
+
+	// The last copy is used while it's within the grace period
+	list.nextSync = time.Time{}
+	revoked, err = list.IsRevoked("revoked-elsewhere")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	assert.Equal(t, 2, backend.calls)
+
+	// Failed syncs aren't retried on every check
+	_, err = list.IsRevoked("revoked-elsewhere")
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.calls)
+
+	list.lastSync = time.Now().Add(-revocationSyncGracePeriod)
+	list.nextSync = time.Time{}
+	_, err = list.IsRevoked("revoked-elsewhere")
+	assert.ErrorIs(t, err, backend.err)
+
+	backend.err = nil
+	list.nextSync = time.Time{}
+	revoked, err = list.IsRevoked("revoked-elsewhere")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestRevocationListNeverSynced(t *testing.T) {
+	backend := &testingRevocationBackend{err: errors.New("connection refused")}
+	list := NewRevocationList(backend)
+
+	_, err := list.IsRevoked("any")
+	assert.ErrorIs(t, err, backend.err)
+
+	// Until the first sync succeeds there is nothing to fall back on
+	_, err = list.IsRevoked("any")
+	assert.ErrorIs(t, err, backend.err)
+}
+
+func TestValidateTokenRevoked(t *testing.T) {
+	setTestingRevocationList(t)
+
+	token, err := GenerateToken(uuid.New(), "customer@example.com")
+	require.NoError(t, err)
+
+	claims, err := ValidateToken(token, TokenTypeAccess)
+	require.NoError(t, err)
+
+	RevokeToken(claims.ID, claims.ExpiresAt.Time)
+
+	_, err = ValidateToken(token, TokenTypeAccess)
+	assert.ErrorIs(t, err, ErrRevokedToken)
+}
+
+func TestValidateTokenWithoutRevocationList(t *testing.T) {
+	token, err := GenerateToken(uuid.New(), "customer@example.com")
+	require.NoError(t, err)
+
+	_, err = ValidateToken(token, TokenTypeAccess)
+	assert.ErrorIs(t, err, ErrNoRevocationList)
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PRPO-skupina-02/common/config"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	DefaultWebAuthnRPID    = "localhost"
+	DefaultWebAuthnRPName  = "CineCore"
+	DefaultWebAuthnOrigins = "http://localhost:5173"
+)
+
+// GetWebAuthnRPID returns the relying party ID passkeys are bound to, the domain
+// of the frontend or a parent of it
+func GetWebAuthnRPID() string {
+	return config.GetEnvDefault("WEBAUTHN_RP_ID", DefaultWebAuthnRPID)
+}
+
+// GetWebAuthnRPName returns the name browsers show when creating a passkey
+func GetWebAuthnRPName() string {
+	return config.GetEnvDefault("WEBAUTHN_RP_NAME", DefaultWebAuthnRPName)
+}
+
+// GetWebAuthnOrigins returns the origins passkey ceremonies may run on, from a
+// comma separated list
+func GetWebAuthnOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(config.GetEnvDefault("WEBAUTHN_ORIGINS", DefaultWebAuthnOrigins), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// NewWebAuthn returns the relying party for passkey registration and login.
+// Ceremonies expire after the timeouts the library suggests to browsers.
+func NewWebAuthn() (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          GetWebAuthnRPID(),
+		RPDisplayName: GetWebAuthnRPName(),
+		RPOrigins:     GetWebAuthnOrigins(),
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true},
+			Registration: webauthn.TimeoutConfig{Enforce: true},
+		},
+	})
+}
+
+// ValidateWebAuthnConfig reports every problem with the configured relying party.
+// Browsers only allow ceremonies on origins whose host is the relying party ID or
+// a subdomain of it.
+func ValidateWebAuthnConfig() error {
+	var errs []error
+
+	rpID := GetWebAuthnRPID()
+	if rpID == "" {
+		errs = append(errs, errors.New("WEBAUTHN_RP_ID must not be empty"))
+	}
+	if GetWebAuthnRPName() == "" {
+		errs = append(errs, errors.New("WEBAUTHN_RP_NAME must not be empty"))
+	}
+
+	origins := GetWebAuthnOrigins()
+	if len(origins) == 0 {
+		errs = append(errs, errors.New("WEBAUTHN_ORIGINS must list at least one origin"))
+	}
+	for _, origin := range origins {
+		parsed, err := url.Parse(origin)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("WEBAUTHN_ORIGINS entry %q must be an origin such as https://example.com", origin))
+			continue
+		}
+		host := parsed.Hostname()
+		if rpID != "" && host != rpID && !strings.HasSuffix(host, "."+rpID) {
+			errs = append(errs, fmt.Errorf("WEBAUTHN_ORIGINS entry %q is not on WEBAUTHN_RP_ID %s", origin, rpID))
+		}
+	}
+
+	return errors.Join(errs...)
+}
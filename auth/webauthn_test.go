@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetWebAuthnOrigins(t *testing.T) {
+	assert.Equal(t, []string{DefaultWebAuthnOrigins}, GetWebAuthnOrigins())
+
+	t.Setenv("WEBAUTHN_ORIGINS", " https://cinecore.example, ,https://admin.cinecore.example ")
+	assert.Equal(t, []string{"https://cinecore.example", "https://admin.cinecore.example"}, GetWebAuthnOrigins())
+}
+
+func TestValidateWebAuthnConfig(t *testing.T) {
+	assert.NoError(t, ValidateWebAuthnConfig())
+
+	t.Setenv("WEBAUTHN_RP_ID", "cinecore.example")
+	t.Setenv("WEBAUTHN_ORIGINS", "https://cinecore.example,https://admin.cinecore.example")
+	assert.NoError(t, ValidateWebAuthnConfig())
+
+	_, err := NewWebAuthn()
+	require.NoError(t, err)
+
+	t.Setenv("WEBAUTHN_ORIGINS", "https://cinecore.example,https://evilcinecore.example,cinecore.example")
+	err = ValidateWebAuthnConfig()
+	assert.ErrorContains(t, err, `"https://evilcinecore.example" is not on WEBAUTHN_RP_ID`)
+	assert.ErrorContains(t, err, `"cinecore.example" must be an origin`)
+	assert.NotContains(t, err.Error(), `"https://cinecore.example"`)
+}
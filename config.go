@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/PRPO-skupina-02/auth/api"
+	"github.com/PRPO-skupina-02/auth/auth"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/auth/password"
+	"github.com/PRPO-skupina-02/auth/ratelimit"
+	"github.com/PRPO-skupina-02/common/config"
+)
+
+const (
+	environmentDevelopment = "development"
+	environmentProduction  = "production"
+)
+
+// requiredEnv lists the env vars that have no default
+var requiredEnv = []string{
+	"POSTGRES_IP",
+	"POSTGRES_PORT",
+	"POSTGRES_USERNAME",
+	"POSTGRES_PASSWORD",
+	"POSTGRES_DATABASE_NAME",
+}
+
+// getEnvironment returns the environment the service runs in. Anything that isn't
+// explicitly development is held to production standards.
+func getEnvironment() string {
+	return config.GetEnvDefault("ENVIRONMENT", environmentProduction)
+}
+
+// validateConfig checks the configuration before anything is started and reports
+// all problems at once, so a deployment doesn't have to be fixed one restart at a time
+func validateConfig() error {
+	var errs []error
+
+	environment := getEnvironment()
+	if environment != environmentDevelopment && environment != environmentProduction {
+		errs = append(errs, fmt.Errorf("ENVIRONMENT must be %s or %s", environmentDevelopment, environmentProduction))
+	}
+
+	for _, key := range requiredEnv {
+		if os.Getenv(key) == "" {
+			errs = append(errs, fmt.Errorf("%s must be set", key))
+		}
+	}
+
+	if err := auth.ValidateSigningConfig(environment == environmentDevelopment); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := auth.ValidateTokenConfig(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := auth.ValidateClientConfig(environment == environmentDevelopment); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := auth.ValidateWebAuthnConfig(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := models.ValidatePasswordHashConfig(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := password.ValidateConfig(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// bcrypt ignores everything after the first 72 bytes
+	if _, ok := models.GetPasswordHasher().(models.BcryptHasher); ok && password.GetPolicy().MaxLength > password.BcryptMaxLength {
+		errs = append(errs, fmt.Errorf("PASSWORD_MAX_LENGTH must not exceed %d with bcrypt", password.BcryptMaxLength))
+	}
+
+	if err := auth.ValidateLockoutConfig(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := ratelimit.ValidateConfig(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := api.ValidateProxyConfig(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
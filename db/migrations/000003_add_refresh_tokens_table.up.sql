@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash varchar UNIQUE NOT NULL,
+    family_id uuid NOT NULL,
+    parent_id uuid REFERENCES refresh_tokens(id) ON DELETE SET NULL,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    user_agent varchar,
+    ip_address varchar
+);
+
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
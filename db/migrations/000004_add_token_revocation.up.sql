@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    jti varchar PRIMARY KEY,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+
+ALTER TABLE users ADD COLUMN tokens_revoked_at timestamptz;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS signing_keys(
+    id varchar PRIMARY KEY,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    algorithm varchar NOT NULL,
+    private_key bytea NOT NULL,
+    active boolean NOT NULL DEFAULT false,
+    retired_at timestamptz,
+    expires_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_signing_keys_active ON signing_keys(active) WHERE active;
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN email_verified_at timestamptz;
+ALTER TABLE users ADD COLUMN email_verification_sent_at timestamptz;
+
+-- Accounts created before verification existed keep working when it's required
+UPDATE users SET email_verified_at = created_at;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_email_change_tokens_user_id;
+DROP TABLE IF EXISTS email_change_tokens;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS email_change_tokens(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash varchar UNIQUE NOT NULL,
+    old_email varchar NOT NULL,
+    new_email varchar NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz
+);
+
+CREATE INDEX idx_email_change_tokens_user_id ON email_change_tokens(user_id);
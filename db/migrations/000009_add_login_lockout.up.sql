@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN failed_login_attempts integer NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN last_failed_login_at timestamptz;
+ALTER TABLE users ADD COLUMN locked_until timestamptz;
+
+CREATE TABLE IF NOT EXISTS ip_login_failures(
+    ip_address varchar PRIMARY KEY,
+    failed_attempts integer NOT NULL DEFAULT 0,
+    last_failed_at timestamptz NOT NULL DEFAULT now(),
+    blocked_until timestamptz
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS rate_limit_buckets(
+    key varchar PRIMARY KEY,
+    tokens double precision NOT NULL,
+    refilled_at timestamptz NOT NULL,
+    full_at timestamptz NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_buckets_full_at ON rate_limit_buckets(full_at);
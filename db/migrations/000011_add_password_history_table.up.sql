@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS password_histories(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash varchar NOT NULL
+);
+
+CREATE INDEX idx_password_histories_user_id_created_at ON password_histories(user_id, created_at);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_mfa_recovery_codes_user_id;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_step;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_enabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret;
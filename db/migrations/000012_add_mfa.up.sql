@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN totp_secret varchar;
+ALTER TABLE users ADD COLUMN totp_enabled_at timestamptz;
+ALTER TABLE users ADD COLUMN totp_last_step bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash varchar NOT NULL,
+    used_at timestamptz
+);
+
+CREATE INDEX idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_webauthn_sessions_expires_at;
+DROP INDEX IF EXISTS idx_webauthn_sessions_token_hash;
+DROP TABLE IF EXISTS webauthn_sessions;
+
+DROP INDEX IF EXISTS idx_webauthn_credentials_user_id;
+DROP INDEX IF EXISTS idx_webauthn_credentials_credential_id;
+DROP TABLE IF EXISTS webauthn_credentials;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS webauthn_credentials(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id bytea NOT NULL,
+    name varchar NOT NULL,
+    credential jsonb NOT NULL,
+    last_used_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_webauthn_credentials_credential_id ON webauthn_credentials(credential_id);
+CREATE INDEX idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+
+CREATE TABLE IF NOT EXISTS webauthn_sessions(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    user_id uuid REFERENCES users(id) ON DELETE CASCADE,
+    ceremony varchar NOT NULL,
+    token_hash varchar NOT NULL,
+    data jsonb NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_webauthn_sessions_token_hash ON webauthn_sessions(token_hash);
+CREATE INDEX idx_webauthn_sessions_expires_at ON webauthn_sessions(expires_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_email_login_codes_user_id;
+DROP TABLE IF EXISTS email_login_codes;
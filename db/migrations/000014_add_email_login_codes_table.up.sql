@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS email_login_codes(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash varchar NOT NULL,
+    token_hash varchar UNIQUE NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz
+);
+
+CREATE INDEX idx_email_login_codes_user_id ON email_login_codes(user_id);
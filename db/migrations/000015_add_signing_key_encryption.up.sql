@@ -0,0 +1 @@
+ALTER TABLE signing_keys ADD COLUMN encrypted boolean NOT NULL DEFAULT false;
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN totp_secret_encrypted boolean NOT NULL DEFAULT false;
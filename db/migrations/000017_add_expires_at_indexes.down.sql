@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_ip_login_failures_last_failed_at;
+DROP INDEX IF EXISTS idx_email_login_codes_expires_at;
+DROP INDEX IF EXISTS idx_email_change_tokens_expires_at;
+DROP INDEX IF EXISTS idx_password_reset_tokens_expires_at;
+DROP INDEX IF EXISTS idx_refresh_tokens_expires_at;
@@ -0,0 +1,5 @@
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+CREATE INDEX idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);
+CREATE INDEX idx_email_change_tokens_expires_at ON email_change_tokens(expires_at);
+CREATE INDEX idx_email_login_codes_expires_at ON email_login_codes(expires_at);
+CREATE INDEX idx_ip_login_failures_last_failed_at ON ip_login_failures(last_failed_at);
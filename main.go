@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"log/slog"
 	"os"
 
 	"github.com/PRPO-skupina-02/auth/api"
+	"github.com/PRPO-skupina-02/auth/auth"
 	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/auth/models"
+	"github.com/PRPO-skupina-02/auth/password"
 	"github.com/PRPO-skupina-02/common/database"
 	"github.com/PRPO-skupina-02/common/logging"
 	"github.com/PRPO-skupina-02/common/validation"
@@ -14,7 +19,15 @@ import (
 )
 
 func main() {
-	err := run()
+	var err error
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "rotate-signing-key":
+		err = rotateSigningKey()
+	case len(os.Args) > 1 && os.Args[1] == "build-breached-filter":
+		err = buildBreachedFilter(os.Args[2:])
+	default:
+		err = run()
+	}
 
 	if err != nil {
 		log.Fatal(err)
@@ -28,6 +41,11 @@ func run() error {
 	logger := logging.GetDefaultLogger()
 	slog.SetDefault(logger)
 
+	err := validateConfig()
+	if err != nil {
+		return err
+	}
+
 	db, err := database.OpenAndMigrateProd(db.MigrationsFS)
 	if err != nil {
 		return err
@@ -40,6 +58,12 @@ func run() error {
 
 	router := gin.Default()
 
+	// Only X-Forwarded-For set by our own proxies tells the client IP
+	err = router.SetTrustedProxies(api.GetTrustedProxies())
+	if err != nil {
+		return err
+	}
+
 	// Add CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
@@ -57,6 +81,27 @@ func run() error {
 
 	api.Register(router, db, trans)
 
+	// Tokens are only accepted once revocations can be checked
+	auth.SetRevocationList(auth.NewRevocationList(models.NewRevocationBackend(db)))
+
+	err = auth.LoadKeys()
+	if err != nil {
+		return err
+	}
+
+	err = auth.ValidateActiveKey(getEnvironment() == environmentDevelopment)
+	if err != nil {
+		return err
+	}
+
+	err = password.LoadBreachedList()
+	if err != nil {
+		return err
+	}
+
+	// Expired tokens, codes and login failures would otherwise pile up forever
+	go models.RunExpiredRowsCleanup(context.Background(), db, models.ExpiredRowsCleanupInterval)
+
 	slog.Info("Server startup complete")
 	err = router.Run(":8080")
 	if err != nil {
@@ -65,3 +110,62 @@ func run() error {
 
 	return nil
 }
+
+// rotateSigningKey promotes a new signing key, e.g. from a scheduled job. Running
+// replicas pick it up on their next key reload.
+func rotateSigningKey() error {
+	logger := logging.GetDefaultLogger()
+	slog.SetDefault(logger)
+
+	err := validateConfig()
+	if err != nil {
+		return err
+	}
+
+	db, err := database.OpenAndMigrateProd(db.MigrationsFS)
+	if err != nil {
+		return err
+	}
+
+	auth.SetKeyring(auth.NewKeyring(models.NewKeyStore(db)))
+
+	err = auth.LoadKeys()
+	if err != nil {
+		return err
+	}
+
+	key, err := auth.RotateSigningKey()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Rotated signing key", "kid", key.ID, "algorithm", key.Method.Alg())
+	return nil
+}
+
+// buildBreachedFilter compacts a downloaded Have I Been Pwned hash list into a
+// bloom filter file, for BREACHED_PASSWORDS_FORMAT=bloom
+func buildBreachedFilter(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: build-breached-filter <hash list> <output file>")
+	}
+
+	output, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+
+	count, err := password.BuildBloomFilter(args[0], output, password.DefaultBloomFalsePositiveRate)
+	if err != nil {
+		output.Close()
+		return err
+	}
+
+	err = output.Close()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Built breached password filter", "hashes", count, "output", args[1])
+	return nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"gorm.io/gorm"
+)
+
+// ExpiredRowsCleanupInterval is how often rows that are no longer needed are deleted
+const ExpiredRowsCleanupInterval = time.Hour
+
+// DeleteExpiredRows deletes the tokens and codes that have expired, which can't
+// be used anymore, and the failed logins of IP addresses that are neither blocked
+// nor counted anymore
+func DeleteExpiredRows(db *gorm.DB, now time.Time) error {
+	expiring := []any{
+		&RefreshToken{},
+		&RevokedToken{},
+		&PasswordResetToken{},
+		&EmailChangeToken{},
+		&EmailLoginCode{},
+	}
+	for _, model := range expiring {
+		if err := db.Where("expires_at < ?", now).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	err := db.Where("last_failed_at < ? AND (blocked_until IS NULL OR blocked_until < ?)", now.Add(-auth.GetLoginLockoutDuration()), now).
+		Delete(&IPLoginFailure{}).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RunExpiredRowsCleanup calls DeleteExpiredRows every interval until the context
+// is done. Failures are logged and retried on the next run.
+func RunExpiredRowsCleanup(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := DeleteExpiredRows(db, time.Now()); err != nil {
+			slog.Error("Failed to delete expired rows", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteExpiredRows(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	valid := now.Add(time.Hour)
+
+	for i, expiresAt := range []time.Time{expired, valid} {
+		refreshToken := RefreshToken{UserID: userID, TokenHash: uuid.NewString(), FamilyID: uuid.New(), ExpiresAt: expiresAt}
+		require.NoError(t, refreshToken.Create(db))
+
+		revokedToken := RevokedToken{JTI: uuid.NewString(), UserID: userID, ExpiresAt: expiresAt}
+		require.NoError(t, revokedToken.Create(db))
+
+		resetToken := PasswordResetToken{UserID: userID, TokenHash: uuid.NewString(), ExpiresAt: expiresAt}
+		require.NoError(t, resetToken.Create(db))
+
+		changeToken := EmailChangeToken{UserID: userID, TokenHash: uuid.NewString(), OldEmail: "customer@example.com", NewEmail: "new@example.com", ExpiresAt: expiresAt}
+		require.NoError(t, changeToken.Create(db))
+
+		loginCode := EmailLoginCode{UserID: userID, TokenHash: uuid.NewString(), CodeHash: uuid.NewString(), ExpiresAt: expiresAt}
+		require.NoError(t, loginCode.Create(db))
+
+		// Failures outside the counting window are only kept while the address is blocked
+		_, err := RecordIPLoginFailure(db, []string{"192.0.2.1", "192.0.2.2"}[i], time.Minute)
+		require.NoError(t, err)
+	}
+
+	_, err = RecordIPLoginFailure(db, "192.0.2.3", time.Minute)
+	require.NoError(t, err)
+	blocked, err := GetIPLoginFailure(db, "192.0.2.3")
+	require.NoError(t, err)
+	require.NoError(t, blocked.BlockUntil(db, now.Add(time.Hour)))
+
+	err = DeleteExpiredRows(db, now.Add(time.Minute))
+	require.NoError(t, err)
+
+	for _, model := range []any{&RefreshToken{}, &RevokedToken{}, &PasswordResetToken{}, &EmailChangeToken{}, &EmailLoginCode{}} {
+		var count int64
+		err := db.Model(model).Count(&count).Error
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	}
+
+	// Only the blocked address is left once the lockout window has passed
+	err = DeleteExpiredRows(db, now.Add(time.Hour-time.Minute))
+	require.NoError(t, err)
+
+	var addresses []string
+	err = db.Model(&IPLoginFailure{}).Pluck("ip_address", &addresses).Error
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.3"}, addresses)
+}
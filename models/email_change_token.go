@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EmailChangeToken is a pending change of a user's email address, confirmed with
+// a single-use token sent to the new address. Only the hash of the token is stored.
+type EmailChangeToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	CreatedAt time.Time
+	UserID    uuid.UUID `gorm:"type:uuid;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	OldEmail  string    `gorm:"not null"`
+	NewEmail  string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+func (t *EmailChangeToken) Create(tx *gorm.DB) error {
+	if err := tx.Create(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *EmailChangeToken) MarkUsed(tx *gorm.DB) error {
+	now := time.Now()
+	if err := tx.Model(t).Update("used_at", now).Error; err != nil {
+		return err
+	}
+	t.UsedAt = &now
+	return nil
+}
+
+// IsValid reports whether the token can still be used to confirm the change
+func (t EmailChangeToken) IsValid() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+// GetEmailChangeTokenByHash loads the token and locks its row until the end of
+// the transaction, so the same token can't be used twice concurrently
+func GetEmailChangeTokenByHash(tx *gorm.DB, tokenHash string) (EmailChangeToken, error) {
+	var token EmailChangeToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return token, err
+	}
+	return token, nil
+}
+
+// InvalidateUserEmailChangeTokens marks all unused email change tokens of the user
+// as used, so only the most recent request can be confirmed
+func InvalidateUserEmailChangeTokens(tx *gorm.DB, userID uuid.UUID) error {
+	err := tx.Model(&EmailChangeToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
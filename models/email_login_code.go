@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EmailLoginCode lets a user log in without their password, with a short code
+// typed from an email or the link in it. Only the hashes of both are stored.
+type EmailLoginCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	CreatedAt time.Time
+	UserID    uuid.UUID `gorm:"type:uuid;not null"`
+	CodeHash  string    `gorm:"not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	// Attempts counts the wrong codes entered for this code
+	Attempts  int       `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+func (c *EmailLoginCode) Create(tx *gorm.DB) error {
+	if err := tx.Create(c).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *EmailLoginCode) MarkUsed(tx *gorm.DB) error {
+	now := time.Now()
+	if err := tx.Model(c).Update("used_at", now).Error; err != nil {
+		return err
+	}
+	c.UsedAt = &now
+	return nil
+}
+
+// RecordAttempt counts a wrong code entered for this code
+func (c *EmailLoginCode) RecordAttempt(tx *gorm.DB) error {
+	if err := tx.Model(c).Update("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+		return err
+	}
+	c.Attempts++
+	return nil
+}
+
+// IsValid reports whether the code can still be used to log in
+func (c EmailLoginCode) IsValid(maxAttempts int) bool {
+	return c.UsedAt == nil && c.Attempts < maxAttempts && time.Now().Before(c.ExpiresAt)
+}
+
+// GetEmailLoginCodeByTokenHash loads the code of a login link and locks its row
+// until the end of the transaction, so the same link can't be used twice concurrently
+func GetEmailLoginCodeByTokenHash(tx *gorm.DB, tokenHash string) (EmailLoginCode, error) {
+	var code EmailLoginCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", tokenHash).First(&code).Error; err != nil {
+		return code, err
+	}
+	return code, nil
+}
+
+// GetLatestEmailLoginCode loads the most recently issued code of the user and
+// locks its row, as only the latest code is accepted
+func GetLatestEmailLoginCode(tx *gorm.DB, userID uuid.UUID) (EmailLoginCode, error) {
+	var code EmailLoginCode
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		First(&code).Error
+	if err != nil {
+		return code, err
+	}
+	return code, nil
+}
+
+// EmailLoginRequestedSince reports whether a login code was issued to the user
+// after the given time, so login emails can be throttled
+func EmailLoginRequestedSince(tx *gorm.DB, userID uuid.UUID, since time.Time) (bool, error) {
+	var count int64
+	err := tx.Model(&EmailLoginCode{}).
+		Where("user_id = ? AND created_at > ?", userID, since).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// InvalidateUserEmailLoginCodes marks all unused login codes of the user as used,
+// so only the most recent email works
+func InvalidateUserEmailLoginCodes(tx *gorm.DB, userID uuid.UUID) error {
+	err := tx.Model(&EmailLoginCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
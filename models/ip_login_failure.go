@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IPLoginFailure counts the failed logins from one IP address across all accounts,
+// so an attacker can't get around the account lockout by trying many accounts
+type IPLoginFailure struct {
+	IPAddress      string `gorm:"primaryKey"`
+	FailedAttempts int    `gorm:"not null;default:0"`
+	LastFailedAt   time.Time
+	BlockedUntil   *time.Time
+}
+
+// IsBlocked reports whether logins from the address are refused
+func (f IPLoginFailure) IsBlocked() bool {
+	return f.BlockedUntil != nil && time.Now().Before(*f.BlockedUntil)
+}
+
+// BlockUntil refuses logins from the address until the given time
+func (f *IPLoginFailure) BlockUntil(tx *gorm.DB, until time.Time) error {
+	if err := tx.Model(f).Update("blocked_until", until).Error; err != nil {
+		return err
+	}
+	f.BlockedUntil = &until
+	return nil
+}
+
+// GetIPLoginFailure returns the failed logins from the address, which are empty
+// if there were none
+func GetIPLoginFailure(tx *gorm.DB, ipAddress string) (IPLoginFailure, error) {
+	failure := IPLoginFailure{IPAddress: ipAddress}
+	if err := tx.Where("ip_address = ?", ipAddress).First(&failure).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return failure, nil
+		}
+		return failure, err
+	}
+	return failure, nil
+}
+
+// RecordIPLoginFailure counts a failed login from the address. Failures further
+// apart than the window start the count again. Unlike the failures of an account,
+// they aren't reset by a successful login, which an attacker could do with an
+// account of their own.
+func RecordIPLoginFailure(tx *gorm.DB, ipAddress string, window time.Duration) (IPLoginFailure, error) {
+	now := time.Now()
+
+	var failure IPLoginFailure
+	err := tx.Raw(`INSERT INTO ip_login_failures (ip_address, failed_attempts, last_failed_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT (ip_address) DO UPDATE SET
+			failed_attempts = CASE WHEN ip_login_failures.last_failed_at > ? THEN ip_login_failures.failed_attempts + 1 ELSE 1 END,
+			last_failed_at = excluded.last_failed_at
+		RETURNING *`, ipAddress, now, now.Add(-window)).Scan(&failure).Error
+	if err != nil {
+		return failure, err
+	}
+	return failure, nil
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MFARecoveryCode is a one-time code that replaces a TOTP code when the user has
+// lost their authenticator. Only the hash of the code is stored.
+type MFARecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	CreatedAt time.Time
+	UserID    uuid.UUID `gorm:"type:uuid;not null"`
+	CodeHash  string    `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+// ReplaceRecoveryCodes discards all recovery codes of the user and stores the
+// given code hashes instead
+func ReplaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID, codeHashes []string) error {
+	if err := DeleteRecoveryCodes(tx, userID); err != nil {
+		return err
+	}
+
+	codes := make([]MFARecoveryCode, len(codeHashes))
+	for i, codeHash := range codeHashes {
+		codes[i] = MFARecoveryCode{UserID: userID, CodeHash: codeHash}
+	}
+	if err := tx.Create(&codes).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the recovery code as used, reporting false if the user
+// has no such unused code
+func UseRecoveryCode(tx *gorm.DB, userID uuid.UUID, codeHash string) (bool, error) {
+	result := tx.Model(&MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes the user has left
+func CountUnusedRecoveryCodes(tx *gorm.DB, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := tx.Model(&MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func DeleteRecoveryCodes(tx *gorm.DB, userID uuid.UUID) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
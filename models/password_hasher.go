@@ -0,0 +1,238 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PRPO-skupina-02/common/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+
+	DefaultBcryptCost        = bcrypt.DefaultCost
+	DefaultArgon2Memory      = 64 * 1024
+	DefaultArgon2Iterations  = 3
+	DefaultArgon2Parallelism = 2
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var (
+	ErrPasswordMismatch    = errors.New("password does not match")
+	ErrUnknownPasswordHash = errors.New("unknown password hash format")
+)
+
+// PasswordHasher hashes passwords with one algorithm and set of parameters, which
+// are encoded in the hash so it can be verified after the configuration changes
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether the hash was made with another algorithm or
+	// other parameters than the hasher uses
+	NeedsRehash(encodedHash string) bool
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h BcryptHasher) NeedsRehash(encodedHash string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher hashes passwords with Argon2id, encoded in the PHC string format
+// $argon2id$v=19$m=<memory KiB>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+type argon2idHash struct {
+	Argon2idHasher
+	salt []byte
+	key  []byte
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) NeedsRehash(encodedHash string) bool {
+	hash, err := decodeArgon2idHash(encodedHash)
+	return err != nil || hash.Argon2idHasher != h || len(hash.key) != argon2KeyLength
+}
+
+func decodeArgon2idHash(encodedHash string) (argon2idHash, error) {
+	var hash argon2idHash
+
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != PasswordHashArgon2id {
+		return hash, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return hash, ErrUnknownPasswordHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hash.Memory, &hash.Iterations, &hash.Parallelism); err != nil {
+		return hash, ErrUnknownPasswordHash
+	}
+
+	var err error
+	if hash.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return hash, ErrUnknownPasswordHash
+	}
+	if hash.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(hash.key) == 0 {
+		return hash, ErrUnknownPasswordHash
+	}
+
+	return hash, nil
+}
+
+// hasherOf returns the hasher with the algorithm and parameters a stored hash was
+// made with
+func hasherOf(encodedHash string) (PasswordHasher, error) {
+	if strings.HasPrefix(encodedHash, "$"+PasswordHashArgon2id+"$") {
+		hash, err := decodeArgon2idHash(encodedHash)
+		if err != nil {
+			return nil, err
+		}
+		return hash.Argon2idHasher, nil
+	}
+
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	if err != nil {
+		return nil, ErrUnknownPasswordHash
+	}
+	return BcryptHasher{Cost: cost}, nil
+}
+
+// VerifyPassword checks the password against a hash made by any of the supported
+// hashers, whatever the configured one is
+func VerifyPassword(encodedHash string, password string) error {
+	if strings.HasPrefix(encodedHash, "$"+PasswordHashArgon2id+"$") {
+		hash, err := decodeArgon2idHash(encodedHash)
+		if err != nil {
+			return err
+		}
+
+		key := argon2.IDKey([]byte(password), hash.salt, hash.Iterations, hash.Memory, hash.Parallelism, uint32(len(hash.key)))
+		if subtle.ConstantTimeCompare(key, hash.key) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	}
+
+	if _, err := bcrypt.Cost([]byte(encodedHash)); err != nil {
+		return ErrUnknownPasswordHash
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPasswordMismatch
+		}
+		return err
+	}
+	return nil
+}
+
+// getUintEnv parses the env var as a positive integer of at most the given bit
+// size. Invalid values fall back to the default, they are reported by
+// ValidatePasswordHashConfig.
+func getUintEnv(key string, def uint64, bitSize int) uint64 {
+	value, err := strconv.ParseUint(config.GetEnvDefault(key, strconv.FormatUint(def, 10)), 10, bitSize)
+	if err != nil || value == 0 {
+		return def
+	}
+	return value
+}
+
+// GetPasswordHasher returns the hasher new passwords are hashed with. Stored
+// hashes made with other algorithms or parameters are replaced on the next login.
+func GetPasswordHasher() PasswordHasher {
+	if config.GetEnvDefault("PASSWORD_HASH_ALGORITHM", PasswordHashBcrypt) == PasswordHashArgon2id {
+		return Argon2idHasher{
+			Memory:      uint32(getUintEnv("ARGON2_MEMORY", DefaultArgon2Memory, 32)),
+			Iterations:  uint32(getUintEnv("ARGON2_ITERATIONS", DefaultArgon2Iterations, 32)),
+			Parallelism: uint8(getUintEnv("ARGON2_PARALLELISM", DefaultArgon2Parallelism, 8)),
+		}
+	}
+
+	return BcryptHasher{
+		Cost: int(getUintEnv("BCRYPT_COST", uint64(DefaultBcryptCost), 8)),
+	}
+}
+
+// ValidatePasswordHashConfig reports every problem with the configured password hashing
+func ValidatePasswordHashConfig() error {
+	var errs []error
+
+	algorithm := config.GetEnvDefault("PASSWORD_HASH_ALGORITHM", PasswordHashBcrypt)
+	if algorithm != PasswordHashBcrypt && algorithm != PasswordHashArgon2id {
+		errs = append(errs, fmt.Errorf("PASSWORD_HASH_ALGORITHM must be %s or %s", PasswordHashBcrypt, PasswordHashArgon2id))
+	}
+
+	if value := config.GetEnvDefault("BCRYPT_COST", ""); value != "" {
+		cost, err := strconv.Atoi(value)
+		if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			errs = append(errs, fmt.Errorf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+		}
+	}
+
+	argon2Params := []struct {
+		key     string
+		bitSize int
+	}{
+		{key: "ARGON2_MEMORY", bitSize: 32},
+		{key: "ARGON2_ITERATIONS", bitSize: 32},
+		{key: "ARGON2_PARALLELISM", bitSize: 8},
+	}
+	for _, param := range argon2Params {
+		value := config.GetEnvDefault(param.key, "")
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseUint(value, 10, param.bitSize)
+		if err != nil || parsed == 0 {
+			errs = append(errs, fmt.Errorf("%s must be a positive number", param.key))
+		}
+	}
+
+	// Argon2 needs at least 8 KiB of memory per lane
+	hasher, ok := GetPasswordHasher().(Argon2idHasher)
+	if ok && hasher.Memory < 8*uint32(hasher.Parallelism) {
+		errs = append(errs, errors.New("ARGON2_MEMORY must be at least 8 KiB per ARGON2_PARALLELISM lane"))
+	}
+
+	return errors.Join(errs...)
+}
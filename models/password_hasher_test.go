@@ -0,0 +1,101 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	hashers := []PasswordHasher{
+		BcryptHasher{Cost: 4},
+		Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1},
+	}
+
+	for _, hasher := range hashers {
+		hash, err := hasher.Hash("customer123")
+		require.NoError(t, err)
+
+		assert.NoError(t, VerifyPassword(hash, "customer123"))
+		assert.ErrorIs(t, VerifyPassword(hash, "wrongpassword"), ErrPasswordMismatch)
+		assert.False(t, hasher.NeedsRehash(hash))
+	}
+}
+
+func TestArgon2idHashFormat(t *testing.T) {
+	hash, err := Argon2idHasher{Memory: 64, Iterations: 2, Parallelism: 1}.Hash("customer123")
+	require.NoError(t, err)
+
+	parts := strings.Split(hash, "$")
+	require.Len(t, parts, 6)
+	assert.Equal(t, "argon2id", parts[1])
+	assert.Equal(t, "v=19", parts[2])
+	assert.Equal(t, "m=64,t=2,p=1", parts[3])
+
+	// Salts are random, so the same password never gives the same hash
+	other, err := Argon2idHasher{Memory: 64, Iterations: 2, Parallelism: 1}.Hash("customer123")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptHash, err := BcryptHasher{Cost: 4}.Hash("customer123")
+	require.NoError(t, err)
+	argon2Hash, err := Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1}.Hash("customer123")
+	require.NoError(t, err)
+
+	assert.True(t, BcryptHasher{Cost: 5}.NeedsRehash(bcryptHash))
+	assert.True(t, BcryptHasher{Cost: 4}.NeedsRehash(argon2Hash))
+	assert.True(t, Argon2idHasher{Memory: 128, Iterations: 1, Parallelism: 1}.NeedsRehash(argon2Hash))
+	assert.True(t, Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1}.NeedsRehash(bcryptHash))
+}
+
+func TestVerifyPasswordUnknownHash(t *testing.T) {
+	assert.ErrorIs(t, VerifyPassword("plaintext", "plaintext"), ErrUnknownPasswordHash)
+	assert.ErrorIs(t, VerifyPassword("$argon2id$v=19$m=64$salt$key", "customer123"), ErrUnknownPasswordHash)
+}
+
+func TestDummyPasswordHash(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", PasswordHashArgon2id)
+	t.Setenv("ARGON2_MEMORY", "64")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+
+	hashers := []PasswordHasher{
+		BcryptHasher{Cost: 5},
+		Argon2idHasher{Memory: 128, Iterations: 2, Parallelism: 1},
+	}
+
+	// Unknown addresses cost as much as the stored hashes still made by the old hasher
+	for _, hasher := range hashers {
+		stored, err := hasher.Hash("customer123")
+		require.NoError(t, err)
+
+		dummy := dummyPasswordHash(stored)
+		assert.False(t, hasher.NeedsRehash(dummy))
+		assert.Equal(t, dummy, dummyPasswordHash(stored))
+	}
+
+	// Without stored hashes the configured hasher is used
+	assert.False(t, GetPasswordHasher().NeedsRehash(dummyPasswordHash("")))
+	assert.False(t, GetPasswordHasher().NeedsRehash(dummyPasswordHash("plaintext")))
+}
+
+func TestGetPasswordHasher(t *testing.T) {
+	assert.Equal(t, BcryptHasher{Cost: DefaultBcryptCost}, GetPasswordHasher())
+
+	t.Setenv("PASSWORD_HASH_ALGORITHM", PasswordHashArgon2id)
+	t.Setenv("ARGON2_MEMORY", "19456")
+	t.Setenv("ARGON2_ITERATIONS", "2")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+	assert.Equal(t, Argon2idHasher{Memory: 19456, Iterations: 2, Parallelism: 1}, GetPasswordHasher())
+	assert.NoError(t, ValidatePasswordHashConfig())
+
+	t.Setenv("ARGON2_PARALLELISM", "300")
+	t.Setenv("BCRYPT_COST", "99")
+	err := ValidatePasswordHashConfig()
+	assert.ErrorContains(t, err, "ARGON2_PARALLELISM")
+	assert.ErrorContains(t, err, "BCRYPT_COST")
+}
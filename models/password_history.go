@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PasswordHistory is a previous password hash of a user, kept so old passwords
+// can't be set again
+type PasswordHistory struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	CreatedAt    time.Time
+	UserID       uuid.UUID `gorm:"type:uuid;not null"`
+	PasswordHash string    `gorm:"not null"`
+}
+
+// recordPasswordHistory remembers a replaced password hash, keeping only the
+// newest keep hashes of the user
+func recordPasswordHistory(tx *gorm.DB, userID uuid.UUID, passwordHash string, keep int) error {
+	if keep > 0 {
+		entry := PasswordHistory{UserID: userID, PasswordHash: passwordHash}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+
+	newest := tx.Model(&PasswordHistory{}).
+		Select("id").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(keep)
+	err := tx.Where("user_id = ? AND id NOT IN (?)", userID, newest).Delete(&PasswordHistory{}).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// getPasswordHistory returns the newest limit previous password hashes of the user
+func getPasswordHistory(tx *gorm.DB, userID uuid.UUID, limit int) ([]PasswordHistory, error) {
+	var history []PasswordHistory
+	err := tx.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// ReplacePassword sets a new password, remembering the replaced one. size is how
+// many recent passwords, the current one included, IsPasswordReused rejects.
+// The user still has to be saved.
+func (u *User) ReplacePassword(tx *gorm.DB, password string, size int) error {
+	if size > 0 {
+		if err := recordPasswordHistory(tx, u.ID, u.PasswordHash, size-1); err != nil {
+			return err
+		}
+	}
+	return u.SetPassword(password)
+}
+
+// IsPasswordReused reports whether the password is the current one or one of the
+// previous passwords of the user, size passwords in total
+func (u User) IsPasswordReused(tx *gorm.DB, password string, size int) (bool, error) {
+	if size < 1 {
+		return false, nil
+	}
+
+	hashes := []string{u.PasswordHash}
+	if size > 1 {
+		history, err := getPasswordHistory(tx, u.ID, size-1)
+		if err != nil {
+			return false, err
+		}
+		for _, entry := range history {
+			hashes = append(hashes, entry.PasswordHash)
+		}
+	}
+
+	for _, hash := range hashes {
+		err := VerifyPassword(hash, password)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, ErrPasswordMismatch) && !errors.Is(err, ErrUnknownPasswordHash) {
+			return false, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PasswordResetToken is a single-use token emailed to a user who forgot their
+// password. Only the hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	CreatedAt time.Time
+	UserID    uuid.UUID `gorm:"type:uuid;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+func (t *PasswordResetToken) Create(tx *gorm.DB) error {
+	if err := tx.Create(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *PasswordResetToken) MarkUsed(tx *gorm.DB) error {
+	now := time.Now()
+	if err := tx.Model(t).Update("used_at", now).Error; err != nil {
+		return err
+	}
+	t.UsedAt = &now
+	return nil
+}
+
+// IsValid reports whether the token can still be used to reset the password
+func (t PasswordResetToken) IsValid() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+// GetPasswordResetTokenByHash loads the token and locks its row until the end of
+// the transaction, so the same token can't be used twice concurrently
+func GetPasswordResetTokenByHash(tx *gorm.DB, tokenHash string) (PasswordResetToken, error) {
+	var token PasswordResetToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return token, err
+	}
+	return token, nil
+}
+
+// PasswordResetRequestedSince reports whether a reset token was issued to the user
+// after the given time, so reset emails can be throttled
+func PasswordResetRequestedSince(tx *gorm.DB, userID uuid.UUID, since time.Time) (bool, error) {
+	var count int64
+	err := tx.Model(&PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", userID, since).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// InvalidateUserPasswordResetTokens marks all unused reset tokens of the user as
+// used, so only the most recent link works
+func InvalidateUserPasswordResetTokens(tx *gorm.DB, userID uuid.UUID) error {
+	err := tx.Model(&PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
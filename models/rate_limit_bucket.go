@@ -0,0 +1,91 @@
+package models
+
+import (
+	"sync"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/ratelimit"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rateLimitCleanupInterval is how often buckets that are full again are deleted
+const rateLimitCleanupInterval = 10 * time.Minute
+
+// RateLimitBucket is a token bucket shared by all replicas. Once it is full
+// again it behaves like a new bucket, so the row can be deleted.
+type RateLimitBucket struct {
+	Key        string  `gorm:"primaryKey"`
+	Tokens     float64 `gorm:"not null"`
+	RefilledAt time.Time
+	FullAt     time.Time
+}
+
+// RateLimitStore keeps the buckets of ratelimit in the database. It uses its own
+// short transactions, so a throttled request doesn't hold a bucket locked.
+type RateLimitStore struct {
+	db          *gorm.DB
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+func NewRateLimitStore(db *gorm.DB) *RateLimitStore {
+	return &RateLimitStore{db: db}
+}
+
+func (s *RateLimitStore) Take(key string, limit ratelimit.Limit) (bool, time.Duration, error) {
+	now := time.Now()
+	if err := s.cleanup(now); err != nil {
+		return false, 0, err
+	}
+
+	var allowed bool
+	var retryAfter time.Duration
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		bucket := ratelimit.NewBucket(limit, now)
+		row := RateLimitBucket{
+			Key:        key,
+			Tokens:     bucket.Tokens,
+			RefilledAt: bucket.RefilledAt,
+			FullAt:     bucket.FullAt(limit),
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return err
+		}
+
+		// Concurrent requests for the same key wait for each other here
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+
+		bucket = ratelimit.Bucket{Tokens: row.Tokens, RefilledAt: row.RefilledAt}
+		allowed, retryAfter = bucket.Take(limit, now)
+
+		return tx.Model(&row).Updates(map[string]interface{}{
+			"tokens":      bucket.Tokens,
+			"refilled_at": bucket.RefilledAt,
+			"full_at":     bucket.FullAt(limit),
+		}).Error
+	})
+	if err != nil {
+		return false, 0, err
+	}
+
+	return allowed, retryAfter, nil
+}
+
+// cleanup deletes the buckets that are full again, at most every rateLimitCleanupInterval
+func (s *RateLimitStore) cleanup(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastCleanup) < rateLimitCleanupInterval {
+		return nil
+	}
+
+	if err := s.db.Where("full_at <= ?", now).Delete(&RateLimitBucket{}).Error; err != nil {
+		return err
+	}
+	s.lastCleanup = now
+	return nil
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefreshToken is the server side record of an issued refresh token. Every
+// rotation creates a child token in the same family, so a replayed parent
+// can be traced back to the whole session.
+type RefreshToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	CreatedAt time.Time
+	UserID    uuid.UUID  `gorm:"type:uuid;not null"`
+	TokenHash string     `gorm:"uniqueIndex;not null"`
+	FamilyID  uuid.UUID  `gorm:"type:uuid;not null"`
+	ParentID  *uuid.UUID `gorm:"type:uuid"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	RevokedAt *time.Time
+	UserAgent string
+	IPAddress string
+}
+
+func (t *RefreshToken) Create(tx *gorm.DB) error {
+	if err := tx.Create(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RefreshToken) Revoke(tx *gorm.DB) error {
+	now := time.Now()
+	if err := tx.Model(t).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	t.RevokedAt = &now
+	return nil
+}
+
+func (t RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+func (t RefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// GetRefreshTokenByHash loads the token and locks its row until the end of the
+// transaction, so two concurrent refreshes with the same token can't both rotate it
+func GetRefreshTokenByHash(tx *gorm.DB, tokenHash string) (RefreshToken, error) {
+	var token RefreshToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return token, err
+	}
+	return token, nil
+}
+
+func RevokeRefreshTokenFamily(tx *gorm.DB, familyID uuid.UUID) error {
+	err := tx.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func RevokeUserRefreshTokens(tx *gorm.DB, userID uuid.UUID) error {
+	err := tx.Model(&RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// IsRefreshTokenActive reports whether the token is known and neither revoked nor
+// expired, without locking it
+func IsRefreshTokenActive(tx *gorm.DB, tokenHash string) (bool, error) {
+	var count int64
+	err := tx.Model(&RefreshToken{}).
+		Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", tokenHash, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
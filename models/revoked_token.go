@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokedToken marks a single access token as no longer valid. Rows are only
+// needed until the token would have expired on its own.
+type RevokedToken struct {
+	JTI       string `gorm:"column:jti;primary_key"`
+	CreatedAt time.Time
+	UserID    uuid.UUID `gorm:"type:uuid;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
+
+func (t *RevokedToken) Create(tx *gorm.DB) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Consume revokes a single-use token and reports whether this call was the first
+// to do so. A concurrent insert of the same token waits for the other transaction,
+// so only one of them gets true.
+func (t *RevokedToken) Consume(tx *gorm.DB) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(t)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RevocationBackend loads revoked tokens from the database for auth.RevocationList
+type RevocationBackend struct {
+	db *gorm.DB
+}
+
+func NewRevocationBackend(db *gorm.DB) *RevocationBackend {
+	return &RevocationBackend{db: db}
+}
+
+func (b *RevocationBackend) RevokedTokensSince(since time.Time) (map[string]time.Time, error) {
+	var revokedTokens []RevokedToken
+	if err := b.db.Where("created_at > ? AND expires_at > ?", since, time.Now()).Find(&revokedTokens).Error; err != nil {
+		return nil, err
+	}
+
+	tokens := make(map[string]time.Time, len(revokedTokens))
+	for _, token := range revokedTokens {
+		tokens[token.JTI] = token.ExpiresAt
+	}
+	return tokens, nil
+}
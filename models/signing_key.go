@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/PRPO-skupina-02/auth/auth"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SigningKey is a key tokens are signed with. At most one key is active; retired
+// keys are kept until the tokens they signed have expired.
+type SigningKey struct {
+	ID         string `gorm:"primary_key"`
+	CreatedAt  time.Time
+	Algorithm  string `gorm:"not null"`
+	PrivateKey []byte `gorm:"not null" json:"-"`
+	Encrypted  bool   `gorm:"default:false"`
+	Active     bool   `gorm:"default:false"`
+	RetiredAt  *time.Time
+	ExpiresAt  *time.Time
+}
+
+func (k *SigningKey) Create(tx *gorm.DB) error {
+	if err := tx.Create(k).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Retire replaces the key as the active one, keeping it for verification until expiresAt
+func (k *SigningKey) Retire(tx *gorm.DB, expiresAt time.Time) error {
+	now := time.Now()
+	updates := map[string]any{
+		"active":     false,
+		"retired_at": now,
+		"expires_at": expiresAt,
+	}
+	if err := tx.Model(k).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	k.Active = false
+	k.RetiredAt = &now
+	k.ExpiresAt = &expiresAt
+	return nil
+}
+
+// GetSigningKeys returns the active key and the retired keys that haven't expired yet
+func GetSigningKeys(tx *gorm.DB) ([]SigningKey, error) {
+	var keys []SigningKey
+	if err := tx.Where("active OR expires_at > ?", time.Now()).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return keys, err
+	}
+	return keys, nil
+}
+
+func GetSigningKey(tx *gorm.DB, id string) (SigningKey, error) {
+	var key SigningKey
+	if err := tx.Where("id = ?", id).First(&key).Error; err != nil {
+		return key, err
+	}
+	return key, nil
+}
+
+// KeyStore persists the keys of auth.Keyring in the database
+type KeyStore struct {
+	db *gorm.DB
+}
+
+func NewKeyStore(db *gorm.DB) *KeyStore {
+	return &KeyStore{db: db}
+}
+
+func (s *KeyStore) LoadKeys() ([]auth.StoredKey, error) {
+	keys, err := GetSigningKeys(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	storedKeys := make([]auth.StoredKey, 0, len(keys))
+	for _, key := range keys {
+		storedKeys = append(storedKeys, auth.StoredKey{
+			ID:         key.ID,
+			Algorithm:  key.Algorithm,
+			PrivateKey: key.PrivateKey,
+			Encrypted:  key.Encrypted,
+			Active:     key.Active,
+		})
+	}
+	return storedKeys, nil
+}
+
+// InitKey stores the first active key. When replicas start at the same time only
+// one of them wins, the unique index on the active key makes the others skip.
+func (s *KeyStore) InitKey(key auth.StoredKey) error {
+	signingKey := SigningKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: key.PrivateKey,
+		Encrypted:  key.Encrypted,
+		Active:     true,
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&signingKey).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *KeyStore) PromoteKey(key auth.StoredKey, retiredUntil time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var current SigningKey
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("active").First(&current).Error
+		if err == nil {
+			if err := current.Retire(tx, retiredUntil); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		signingKey := SigningKey{
+			ID:         key.ID,
+			Algorithm:  key.Algorithm,
+			PrivateKey: key.PrivateKey,
+			Encrypted:  key.Encrypted,
+			Active:     true,
+		}
+		return signingKey.Create(tx)
+	})
+}
@@ -2,11 +2,12 @@ package models
 
 import (
 	"errors"
+	"sync"
 	"time"
 
+	"github.com/PRPO-skupina-02/auth/auth"
 	"github.com/PRPO-skupina-02/common/request"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -26,29 +27,269 @@ func (r UserRole) IsValid() bool {
 	return false
 }
 
+// RequiresMFA reports whether users of the role have to log in with a second
+// factor. It is optional for customers.
+func (r UserRole) RequiresMFA() bool {
+	return r == RoleAdmin || r == RoleEmployee
+}
+
 type User struct {
-	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	Email        string `gorm:"uniqueIndex;not null"`
-	PasswordHash string `gorm:"not null" json:"-"`
-	FirstName    string
-	LastName     string
-	Role         UserRole `gorm:"type:user_role;default:'customer'"`
-	Active       bool     `gorm:"default:true"`
+	ID                      uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	Email                   string `gorm:"uniqueIndex;not null"`
+	PasswordHash            string `gorm:"not null" json:"-"`
+	FirstName               string
+	LastName                string
+	Role                    UserRole `gorm:"type:user_role;default:'customer'"`
+	Active                  bool     `gorm:"default:true"`
+	TokensRevokedAt         *time.Time
+	EmailVerifiedAt         *time.Time
+	EmailVerificationSentAt *time.Time
+	FailedLoginAttempts     int `gorm:"not null;default:0"`
+	LastFailedLoginAt       *time.Time
+	LockedUntil             *time.Time
+	TOTPSecret              *string `json:"-"`
+	// TOTPSecretEncrypted is set when TOTPSecret is encrypted with
+	// SIGNING_KEY_ENCRYPTION_KEY
+	TOTPSecretEncrypted bool `gorm:"not null;default:false" json:"-"`
+	TOTPEnabledAt       *time.Time
+	// TOTPLastStep is the time step of the last accepted TOTP code, so a code
+	// can't be used twice
+	TOTPLastStep int64 `gorm:"not null;default:0"`
 }
 
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrUserInactive       = errors.New("user account is inactive")
+	ErrAccountLocked      = errors.New("account is temporarily locked")
+)
+
+// dummyPasswordHashes holds a hash of a dummy password per hasher, made the first
+// time it is needed
+var dummyPasswordHashes sync.Map
+
+// dummyPasswordHash returns a hash to compare against when there is no password
+// to check. It is made with the algorithm and parameters of the given stored hash,
+// so while hashes are migrated to another hasher on login, unknown addresses take
+// as long as the accounts still on the old one. Without a usable stored hash the
+// configured hasher is used.
+func dummyPasswordHash(storedHash string) string {
+	hasher, err := hasherOf(storedHash)
+	if err != nil {
+		hasher = GetPasswordHasher()
+	}
+
+	if hash, ok := dummyPasswordHashes.Load(hasher); ok {
+		return hash.(string)
+	}
+	hash, _ := hasher.Hash("dummy password")
+	dummyPasswordHashes.Store(hasher, hash)
+	return hash
+}
+
+// SetPassword hashes the password with the configured hasher
 func (u *User) SetPassword(password string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := GetPasswordHasher().Hash(password)
 	if err != nil {
 		return err
 	}
-	u.PasswordHash = string(hash)
+	u.PasswordHash = hash
 	return nil
 }
 
 func (u *User) CheckPassword(password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
+	return VerifyPassword(u.PasswordHash, password)
+}
+
+// rehashPassword replaces the stored hash when it was made with another algorithm
+// or other parameters than are configured now. It needs the plain password, so
+// it can only happen when the user logs in.
+func (u *User) rehashPassword(tx *gorm.DB, password string) error {
+	if !GetPasswordHasher().NeedsRehash(u.PasswordHash) {
+		return nil
+	}
+
+	if err := u.SetPassword(password); err != nil {
+		return err
+	}
+	if err := tx.Model(u).Update("password_hash", u.PasswordHash).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// RevokeAllTokens ends every session of the user, invalidating all of their
+// refresh tokens and every access token issued so far
+func (u *User) RevokeAllTokens(tx *gorm.DB) error {
+	if err := RevokeUserRefreshTokens(tx, u.ID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := tx.Model(u).Update("tokens_revoked_at", now).Error; err != nil {
+		return err
+	}
+	u.TokensRevokedAt = &now
+	return nil
+}
+
+// TokensRevokedSince reports whether a token issued at the given time was revoked
+// by RevokeAllTokens. Token timestamps only have second precision, so a token
+// issued within the same second is treated as revoked as well.
+func (u User) TokensRevokedSince(issuedAt time.Time) bool {
+	return u.TokensRevokedAt != nil && !issuedAt.After(*u.TokensRevokedAt)
+}
+
+func (u User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) MarkEmailVerified(tx *gorm.DB) error {
+	now := time.Now()
+	if err := tx.Model(u).Update("email_verified_at", now).Error; err != nil {
+		return err
+	}
+	u.EmailVerifiedAt = &now
+	return nil
+}
+
+// VerificationSentWithin reports whether a verification email was sent to the
+// user within the given interval, so resends can be throttled
+func (u User) VerificationSentWithin(interval time.Duration) bool {
+	return u.EmailVerificationSentAt != nil && time.Since(*u.EmailVerificationSentAt) < interval
+}
+
+func (u *User) MarkVerificationSent(tx *gorm.DB) error {
+	now := time.Now()
+	if err := tx.Model(u).Update("email_verification_sent_at", now).Error; err != nil {
+		return err
+	}
+	u.EmailVerificationSentAt = &now
+	return nil
+}
+
+// IsLocked reports whether logins to the account are refused after failed attempts
+func (u User) IsLocked() bool {
+	return u.LockedUntil != nil && time.Now().Before(*u.LockedUntil)
+}
+
+// LockUntil refuses logins to the account until the given time
+func (u *User) LockUntil(tx *gorm.DB, until time.Time) error {
+	if err := tx.Model(u).Update("locked_until", until).Error; err != nil {
+		return err
+	}
+	u.LockedUntil = &until
+	return nil
+}
+
+// ResetFailedLogins forgets all failed logins and lifts the lock, after a
+// successful login, a password reset or when an admin unlocks the account
+func (u *User) ResetFailedLogins(tx *gorm.DB) error {
+	err := tx.Model(u).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+		"locked_until":          nil,
+	}).Error
+	if err != nil {
+		return err
+	}
+	u.FailedLoginAttempts = 0
+	u.LastFailedLoginAt = nil
+	u.LockedUntil = nil
+	return nil
+}
+
+// HasMFA reports whether logins need a second factor besides the password
+func (u User) HasMFA() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// StartTOTPEnrollment stores a TOTP secret that isn't used for logins until the
+// user confirms it with EnableTOTP. The secret is encrypted if an encryption key
+// is configured.
+func (u *User) StartTOTPEnrollment(tx *gorm.DB, secret string) error {
+	stored, encrypted, err := auth.SealTOTPSecret(u.ID, secret)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(u).Updates(map[string]interface{}{
+		"totp_secret":           stored,
+		"totp_secret_encrypted": encrypted,
+		"totp_enabled_at":       nil,
+		"totp_last_step":        0,
+	}).Error
+	if err != nil {
+		return err
+	}
+	u.TOTPSecret = &stored
+	u.TOTPSecretEncrypted = encrypted
+	u.TOTPEnabledAt = nil
+	u.TOTPLastStep = 0
+	return nil
+}
+
+// GetTOTPSecret returns the TOTP secret of the user in plaintext, or an empty
+// string if there is none
+func (u User) GetTOTPSecret() (string, error) {
+	if u.TOTPSecret == nil {
+		return "", nil
+	}
+	return auth.OpenTOTPSecret(u.ID, *u.TOTPSecret, u.TOTPSecretEncrypted)
+}
+
+// EnableTOTP requires TOTP codes on login from now on. step is the time step of
+// the code that confirmed the secret.
+func (u *User) EnableTOTP(tx *gorm.DB, step int64) error {
+	now := time.Now()
+	err := tx.Model(u).Updates(map[string]interface{}{
+		"totp_enabled_at": now,
+		"totp_last_step":  step,
+	}).Error
+	if err != nil {
+		return err
+	}
+	u.TOTPEnabledAt = &now
+	u.TOTPLastStep = step
+	return nil
+}
+
+// UseTOTPStep records that the code of the time step was used, reporting false if
+// a code of the same or a later step was already accepted
+func (u *User) UseTOTPStep(tx *gorm.DB, step int64) (bool, error) {
+	result := tx.Model(&User{}).
+		Where("id = ? AND totp_last_step < ?", u.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	return true, nil
+}
+
+// DisableMFA removes the TOTP secret and all recovery codes of the user
+func (u *User) DisableMFA(tx *gorm.DB) error {
+	err := tx.Model(u).Updates(map[string]interface{}{
+		"totp_secret":           nil,
+		"totp_secret_encrypted": false,
+		"totp_enabled_at":       nil,
+		"totp_last_step":        0,
+	}).Error
+	if err != nil {
+		return err
+	}
+	if err := DeleteRecoveryCodes(tx, u.ID); err != nil {
+		return err
+	}
+	u.TOTPSecret = nil
+	u.TOTPSecretEncrypted = false
+	u.TOTPEnabledAt = nil
+	u.TOTPLastStep = 0
+	return nil
 }
 
 func (u *User) Create(tx *gorm.DB) error {
@@ -125,22 +366,87 @@ func UserExists(tx *gorm.DB, email string) (bool, error) {
 	return count > 0, nil
 }
 
+// getLoginUser returns the user with the email, and the password hash of a random
+// account for when there is none. Both are fetched in one query, so it takes as
+// long whether or not the account exists.
+func getLoginUser(tx *gorm.DB, email string) (User, string, error) {
+	var users []User
+	err := tx.Raw(`(SELECT * FROM users WHERE email = ? LIMIT 1)
+		UNION ALL (SELECT * FROM users WHERE id >= ? ORDER BY id LIMIT 1)
+		UNION ALL (SELECT * FROM users ORDER BY id LIMIT 1)`,
+		email, uuid.New()).Scan(&users).Error
+	if err != nil {
+		return User{}, "", err
+	}
+
+	for _, user := range users {
+		if user.Email == email {
+			return user, "", nil
+		}
+	}
+
+	sampleHash := ""
+	if len(users) > 0 {
+		sampleHash = users[0].PasswordHash
+	}
+	return User{}, sampleHash, gorm.ErrRecordNotFound
+}
+
+// ValidateCredentials checks the email and password. The password is hashed even
+// when the account doesn't exist or can't log in, so the time taken doesn't reveal
+// which addresses have accounts. The errors tell callers why the login failed, but
+// must not be shown to the client.
 func ValidateCredentials(tx *gorm.DB, email, password string) (*User, error) {
-	user, err := GetUserByEmail(tx, email)
+	user, sampleHash, err := getLoginUser(tx, email)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid credentials")
+			_ = VerifyPassword(dummyPasswordHash(sampleHash), password)
+			return nil, ErrInvalidCredentials
 		}
 		return nil, err
 	}
 
+	passwordErr := user.CheckPassword(password)
+
 	if !user.Active {
-		return nil, errors.New("user account is inactive")
+		return nil, ErrUserInactive
 	}
 
-	if err := user.CheckPassword(password); err != nil {
-		return nil, errors.New("invalid credentials")
+	// Checked regardless of the password, so a locked account can't be brute-forced
+	if user.IsLocked() {
+		return nil, ErrAccountLocked
+	}
+
+	if passwordErr != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := user.rehashPassword(tx, password); err != nil {
+		return nil, err
 	}
 
 	return &user, nil
 }
+
+// RecordFailedLogin counts a failed login to the account with the given email and
+// returns the updated user, or nil if there is no such account. Failures further
+// apart than the window start the count again. The count is incremented in the
+// database, so concurrent attempts can't overwrite each other's failures.
+func RecordFailedLogin(tx *gorm.DB, email string, window time.Duration) (*User, error) {
+	now := time.Now()
+
+	var users []User
+	err := tx.Raw(`UPDATE users SET
+			failed_login_attempts = CASE WHEN last_failed_login_at > ? THEN failed_login_attempts + 1 ELSE 1 END,
+			last_failed_login_at = ?
+		WHERE email = ?
+		RETURNING *`, now.Add(-window), now, email).Scan(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, nil
+	}
+	return &users[0], nil
+}
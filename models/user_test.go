@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/PRPO-skupina-02/auth/db"
+	"github.com/PRPO-skupina-02/common/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestGetLoginUser(t *testing.T) {
+	db, fixtures := database.PrepareTestDatabase(t, db.FixtureFS, db.MigrationsFS)
+
+	err := fixtures.Load()
+	require.NoError(t, err)
+
+	user, _, err := getLoginUser(db, "customer@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, uuid.MustParse("00000000-0000-0000-0000-000000000003"), user.ID)
+
+	// Unknown addresses get the hash of some account to time the dummy comparison by
+	_, sampleHash, err := getLoginUser(db, "nobody@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = hasherOf(sampleHash)
+	assert.NoError(t, err)
+}
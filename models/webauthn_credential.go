@@ -0,0 +1,130 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WebAuthnCredential is a passkey of a user. The credential holds the public key
+// and the authenticator state the webauthn library checks on every login.
+type WebAuthnCredential struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	CreatedAt    time.Time
+	UserID       uuid.UUID           `gorm:"type:uuid;not null"`
+	CredentialID []byte              `gorm:"uniqueIndex;not null"`
+	Name         string              `gorm:"not null"`
+	Credential   webauthn.Credential `gorm:"type:jsonb;serializer:json;not null"`
+	LastUsedAt   *time.Time
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+func (c *WebAuthnCredential) Create(tx *gorm.DB) error {
+	if err := tx.Create(c).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *WebAuthnCredential) Delete(tx *gorm.DB) error {
+	if err := tx.Delete(c).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// RecordUse stores the authenticator state after a successful login, so the next
+// login can detect a cloned authenticator by its sign count
+func (c *WebAuthnCredential) RecordUse(tx *gorm.DB, credential webauthn.Credential) error {
+	now := time.Now()
+	c.Credential = credential
+	c.LastUsedAt = &now
+	if err := tx.Model(c).Select("credential", "last_used_at").Updates(c).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetUserWebAuthnCredentials(tx *gorm.DB, userID uuid.UUID) ([]WebAuthnCredential, error) {
+	var credentials []WebAuthnCredential
+	if err := tx.Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error; err != nil {
+		return credentials, err
+	}
+	return credentials, nil
+}
+
+func GetUserWebAuthnCredential(tx *gorm.DB, userID uuid.UUID, id uuid.UUID) (WebAuthnCredential, error) {
+	var credential WebAuthnCredential
+	if err := tx.Where("user_id = ? AND id = ?", userID, id).First(&credential).Error; err != nil {
+		return credential, err
+	}
+	return credential, nil
+}
+
+func CountUserWebAuthnCredentials(tx *gorm.DB, userID uuid.UUID) (int64, error) {
+	var count int64
+	if err := tx.Model(&WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// WebAuthnUser is a user together with their passkeys, as the webauthn library
+// expects them during a ceremony
+type WebAuthnUser struct {
+	User
+	Credentials []WebAuthnCredential
+}
+
+// GetWebAuthnUser loads the passkeys of the user
+func GetWebAuthnUser(tx *gorm.DB, user User) (WebAuthnUser, error) {
+	credentials, err := GetUserWebAuthnCredentials(tx, user.ID)
+	if err != nil {
+		return WebAuthnUser{}, err
+	}
+	return WebAuthnUser{User: user, Credentials: credentials}, nil
+}
+
+// WebAuthnID returns the user handle stored on passkeys, which identifies the
+// user in passwordless logins. It is the user ID, so it reveals nothing else.
+func (u WebAuthnUser) WebAuthnID() []byte {
+	return u.ID[:]
+}
+
+func (u WebAuthnUser) WebAuthnName() string {
+	return u.Email
+}
+
+func (u WebAuthnUser) WebAuthnDisplayName() string {
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if name == "" {
+		return u.Email
+	}
+	return name
+}
+
+func (u WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.Credentials))
+	for i, credential := range u.Credentials {
+		credentials[i] = credential.Credential
+	}
+	return credentials
+}
+
+// FindCredential returns the passkey with the given credential ID, or nil if the
+// user has no such passkey
+func (u WebAuthnUser) FindCredential(credentialID []byte) *WebAuthnCredential {
+	for i := range u.Credentials {
+		if bytes.Equal(u.Credentials[i].CredentialID, credentialID) {
+			return &u.Credentials[i]
+		}
+	}
+	return nil
+}
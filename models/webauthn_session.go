@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+	WebAuthnCeremonyMFA          = "mfa"
+)
+
+// WebAuthnSession holds the challenge of a passkey ceremony between its start and
+// finish. The client only gets a token for it, of which the hash is stored.
+type WebAuthnSession struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	CreatedAt time.Time
+	// UserID is nil for passwordless logins, where the user isn't known until the
+	// passkey is presented
+	UserID    *uuid.UUID           `gorm:"type:uuid"`
+	Ceremony  string               `gorm:"not null"`
+	TokenHash string               `gorm:"uniqueIndex;not null"`
+	Data      webauthn.SessionData `gorm:"type:jsonb;serializer:json;not null"`
+	ExpiresAt time.Time            `gorm:"not null"`
+}
+
+func (WebAuthnSession) TableName() string {
+	return "webauthn_sessions"
+}
+
+// Create stores the session and discards expired ones, as most ceremonies that
+// are started are never finished
+func (s *WebAuthnSession) Create(tx *gorm.DB) error {
+	if err := tx.Where("expires_at < ?", time.Now()).Delete(&WebAuthnSession{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(s).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// IsValid reports whether the session belongs to the ceremony and user, and
+// hasn't expired
+func (s WebAuthnSession) IsValid(ceremony string, userID *uuid.UUID) bool {
+	if s.Ceremony != ceremony || time.Now().After(s.ExpiresAt) {
+		return false
+	}
+	if s.UserID == nil || userID == nil {
+		return s.UserID == nil && userID == nil
+	}
+	return *s.UserID == *userID
+}
+
+// TakeWebAuthnSession loads and deletes the session, so every ceremony can only be
+// finished once, whether or not it succeeds
+func TakeWebAuthnSession(tx *gorm.DB, tokenHash string) (WebAuthnSession, error) {
+	var session WebAuthnSession
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", tokenHash).First(&session).Error; err != nil {
+		return session, err
+	}
+	if err := tx.Delete(&session).Error; err != nil {
+		return session, err
+	}
+	return session, nil
+}
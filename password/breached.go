@@ -0,0 +1,285 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/PRPO-skupina-02/common/config"
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+const (
+	BreachedFormatHIBP  = "hibp"
+	BreachedFormatBloom = "bloom"
+
+	DefaultBreachedFormat = BreachedFormatHIBP
+
+	// DefaultBloomFalsePositiveRate is the share of passwords a built bloom filter
+	// wrongly reports as breached
+	DefaultBloomFalsePositiveRate = 0.001
+)
+
+// BreachedList is a set of passwords known from data breaches
+type BreachedList interface {
+	Contains(password string) (bool, error)
+}
+
+// breachedList is the list loaded at startup, nil if none is configured
+var breachedList BreachedList
+
+// SetBreachedList replaces the list new passwords are checked against
+func SetBreachedList(list BreachedList) {
+	breachedList = list
+}
+
+// GetBreachedFile returns the path of the breached password list, empty if there
+// is none
+func GetBreachedFile() string {
+	return config.GetEnvDefault("BREACHED_PASSWORDS_FILE", "")
+}
+
+// GetBreachedFormat returns the format of the breached password list
+func GetBreachedFormat() string {
+	return strings.ToLower(config.GetEnvDefault("BREACHED_PASSWORDS_FORMAT", DefaultBreachedFormat))
+}
+
+// LoadBreachedList opens the configured breached password list. Without one,
+// passwords aren't checked for breaches.
+func LoadBreachedList() error {
+	path := GetBreachedFile()
+	if path == "" {
+		SetBreachedList(nil)
+		return nil
+	}
+
+	var list BreachedList
+	var err error
+	switch GetBreachedFormat() {
+	case BreachedFormatHIBP:
+		list, err = OpenHashList(path)
+	case BreachedFormatBloom:
+		list, err = LoadBloomFilter(path)
+	default:
+		err = fmt.Errorf("unknown breached password list format %q", GetBreachedFormat())
+	}
+	if err != nil {
+		return err
+	}
+
+	SetBreachedList(list)
+	return nil
+}
+
+// ValidateBreachedConfig reports every problem with the configured breached
+// password list
+func ValidateBreachedConfig() error {
+	var errs []error
+
+	format := GetBreachedFormat()
+	if format != BreachedFormatHIBP && format != BreachedFormatBloom {
+		errs = append(errs, fmt.Errorf("BREACHED_PASSWORDS_FORMAT must be %s or %s", BreachedFormatHIBP, BreachedFormatBloom))
+	}
+
+	if path := GetBreachedFile(); path != "" {
+		if info, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("BREACHED_PASSWORDS_FILE can't be read: %w", err))
+		} else if info.IsDir() {
+			errs = append(errs, errors.New("BREACHED_PASSWORDS_FILE must be a file"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// passwordSHA1 is the SHA-1 digest breached password lists are keyed by
+func passwordSHA1(password string) [sha1.Size]byte {
+	return sha1.Sum([]byte(password))
+}
+
+// HashList is a breached password list in the format of the Have I Been Pwned
+// download: one uppercase hex SHA-1 per line, optionally followed by a colon and
+// the number of breaches, sorted by hash. It is searched on disk, as the full
+// list is too large to keep in memory.
+type HashList struct {
+	file *os.File
+	size int64
+}
+
+// OpenHashList opens a hash list file. The file stays open for the lifetime of
+// the service.
+func OpenHashList(path string) (*HashList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &HashList{file: file, size: info.Size()}, nil
+}
+
+// Close closes the hash list file
+func (l *HashList) Close() error {
+	return l.file.Close()
+}
+
+// Contains binary searches the file by byte offset for the hash of the password
+func (l *HashList) Contains(password string) (bool, error) {
+	digest := passwordSHA1(password)
+	target := strings.ToUpper(hex.EncodeToString(digest[:]))
+
+	// Find the lowest offset whose following line doesn't sort before the target
+	low, high := int64(0), l.size
+	for low < high {
+		mid := low + (high-low)/2
+		hash, ok, err := l.hashAt(mid)
+		if err != nil {
+			return false, err
+		}
+		if ok && hash < target {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+
+	hash, ok, err := l.hashAt(low)
+	if err != nil {
+		return false, err
+	}
+	return ok && hash == target, nil
+}
+
+// hashAt returns the hash on the first line starting at or after offset, and
+// false if there is no such line
+func (l *HashList) hashAt(offset int64) (string, bool, error) {
+	start := offset
+	if start > 0 {
+		// Start one byte early, so a line starting exactly at offset isn't skipped
+		start--
+	}
+	reader := bufio.NewReaderSize(io.NewSectionReader(l.file, start, l.size-start), 128)
+
+	if offset > 0 {
+		if _, err := reader.ReadSlice('\n'); err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", false, nil
+			}
+			if !errors.Is(err, bufio.ErrBufferFull) {
+				return "", false, err
+			}
+			// The rest of an overlong line, which can only be malformed
+			if _, err := reader.ReadString('\n'); err != nil {
+				if errors.Is(err, io.EOF) {
+					return "", false, nil
+				}
+				return "", false, err
+			}
+		}
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", false, err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", false, nil
+	}
+
+	hash, _, _ := strings.Cut(line, ":")
+	return strings.ToUpper(hash), true, nil
+}
+
+// BloomFilter is a breached password list compacted into a bloom filter of SHA-1
+// digests. It fits in memory, at the cost of rejecting a small share of
+// passwords that were never breached.
+type BloomFilter struct {
+	filter *bloom.BloomFilter
+}
+
+// LoadBloomFilter reads a bloom filter written by BuildBloomFilter
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	filter := &bloom.BloomFilter{}
+	if _, err := filter.ReadFrom(bufio.NewReader(file)); err != nil {
+		return nil, fmt.Errorf("invalid bloom filter file: %w", err)
+	}
+
+	return &BloomFilter{filter: filter}, nil
+}
+
+// Contains reports whether the password is probably in the filter
+func (f *BloomFilter) Contains(password string) (bool, error) {
+	digest := passwordSHA1(password)
+	return f.filter.Test(digest[:]), nil
+}
+
+// BuildBloomFilter compacts a hash list into a bloom filter with the given false
+// positive rate. The hash list is read twice, first to size the filter.
+func BuildBloomFilter(hashListPath string, output io.Writer, falsePositiveRate float64) (uint, error) {
+	var count uint
+	err := readHashList(hashListPath, func([]byte) {
+		count++
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	filter := bloom.NewWithEstimates(max(count, 1), falsePositiveRate)
+	err = readHashList(hashListPath, func(digest []byte) {
+		filter.Add(digest)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := filter.WriteTo(output); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// readHashList calls fn with the decoded digest of every line of a hash list
+func readHashList(path string, fn func(digest []byte)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	digest := make([]byte, sha1.Size)
+	for line := 1; scanner.Scan(); line++ {
+		hash, _, _ := bytes.Cut(bytes.TrimSpace(scanner.Bytes()), []byte(":"))
+		if len(hash) == 0 {
+			continue
+		}
+		if len(hash) != hex.EncodedLen(sha1.Size) {
+			return fmt.Errorf("line %d of %s is not a SHA-1 hash", line, path)
+		}
+		if _, err := hex.Decode(digest, hash); err != nil {
+			return fmt.Errorf("line %d of %s is not a SHA-1 hash", line, path)
+		}
+		fn(digest)
+	}
+
+	return scanner.Err()
+}
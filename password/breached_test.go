@@ -0,0 +1,140 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var breachedPasswords = []string{"zebra-lamp-42", "correct horse battery staple", "hunter2", "Tr0ub4dor&3"}
+
+// writeHashList writes the passwords, padded with filler hashes, in the Have I
+// Been Pwned download format
+func writeHashList(t *testing.T, passwords []string) string {
+	var hashes []string
+	for _, password := range passwords {
+		digest := sha1.Sum([]byte(password))
+		hashes = append(hashes, strings.ToUpper(hex.EncodeToString(digest[:])))
+	}
+	for i := range 500 {
+		digest := sha1.Sum([]byte(fmt.Sprintf("filler-%d", i)))
+		hashes = append(hashes, strings.ToUpper(hex.EncodeToString(digest[:])))
+	}
+	sort.Strings(hashes)
+
+	var content strings.Builder
+	for i, hash := range hashes {
+		fmt.Fprintf(&content, "%s:%d\r\n", hash, i*37%100000+1)
+	}
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content.String()), 0o600))
+	return path
+}
+
+func TestHashList(t *testing.T) {
+	list, err := OpenHashList(writeHashList(t, breachedPasswords))
+	require.NoError(t, err)
+	defer list.Close()
+
+	for _, password := range breachedPasswords {
+		breached, err := list.Contains(password)
+		require.NoError(t, err)
+		assert.True(t, breached, password)
+	}
+
+	for _, password := range []string{"zebra-lamp-43", "filler", "", "Hunter2"} {
+		breached, err := list.Contains(password)
+		require.NoError(t, err)
+		assert.False(t, breached, password)
+	}
+}
+
+func TestHashListEdges(t *testing.T) {
+	// The lowest and highest hashes are the first and last lines
+	digests := map[string]string{}
+	for i := range 500 {
+		password := fmt.Sprintf("filler-%d", i)
+		digest := sha1.Sum([]byte(password))
+		digests[strings.ToUpper(hex.EncodeToString(digest[:]))] = password
+	}
+	hashes := make([]string, 0, len(digests))
+	for hash := range digests {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	list, err := OpenHashList(writeHashList(t, nil))
+	require.NoError(t, err)
+	defer list.Close()
+
+	for _, hash := range []string{hashes[0], hashes[len(hashes)-1]} {
+		breached, err := list.Contains(digests[hash])
+		require.NoError(t, err)
+		assert.True(t, breached, digests[hash])
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+	emptyList, err := OpenHashList(empty)
+	require.NoError(t, err)
+	defer emptyList.Close()
+
+	breached, err := emptyList.Contains("hunter2")
+	require.NoError(t, err)
+	assert.False(t, breached)
+}
+
+func TestBloomFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.bloom")
+	output, err := os.Create(path)
+	require.NoError(t, err)
+
+	count, err := BuildBloomFilter(writeHashList(t, breachedPasswords), output, DefaultBloomFalsePositiveRate)
+	require.NoError(t, err)
+	require.NoError(t, output.Close())
+	assert.Equal(t, uint(len(breachedPasswords)+500), count)
+
+	filter, err := LoadBloomFilter(path)
+	require.NoError(t, err)
+
+	for _, password := range breachedPasswords {
+		breached, err := filter.Contains(password)
+		require.NoError(t, err)
+		assert.True(t, breached, password)
+	}
+
+	breached, err := filter.Contains("zebra-lamp-43")
+	require.NoError(t, err)
+	assert.False(t, breached)
+}
+
+func TestBuildBloomFilterInvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.txt")
+	require.NoError(t, os.WriteFile(path, []byte("not a hash:1\n"), 0o600))
+
+	_, err := BuildBloomFilter(path, &strings.Builder{}, DefaultBloomFalsePositiveRate)
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestPolicyCheckBreached(t *testing.T) {
+	list, err := OpenHashList(writeHashList(t, breachedPasswords))
+	require.NoError(t, err)
+	defer list.Close()
+
+	policy := Policy{MinLength: 8, MaxLength: BcryptMaxLength, Breached: list}
+
+	var policyErr *PolicyError
+	require.ErrorAs(t, policy.Check("zebra-lamp-42"), &policyErr)
+	assert.Equal(t, ViolationBreached, policyErr.Violation)
+
+	assert.NoError(t, policy.Check("zebra-lamp-43"))
+}
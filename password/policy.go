@@ -0,0 +1,185 @@
+// Package password checks new passwords against the configured password policy.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PRPO-skupina-02/common/config"
+)
+
+const (
+	DefaultMinLength        = 8
+	DefaultMaxLength        = BcryptMaxLength
+	DefaultCharacterClasses = 1
+	DefaultMinStrength      = 2
+	DefaultHistorySize      = 5
+
+	// BcryptMaxLength is the number of bytes bcrypt hashes, anything longer is ignored
+	BcryptMaxLength = 72
+)
+
+// Violation is a rule of the policy a password breaks. Violations are also the
+// keys of their translated messages.
+type Violation string
+
+const (
+	ViolationTooShort         Violation = "password_too_short"
+	ViolationTooLong          Violation = "password_too_long"
+	ViolationCharacterClasses Violation = "password_character_classes"
+	ViolationPersonalInfo     Violation = "password_personal_info"
+	ViolationTooWeak          Violation = "password_too_weak"
+	ViolationBreached         Violation = "password_breached"
+	// ViolationReused isn't checked by Policy, as it needs the password history
+	ViolationReused Violation = "password_reused"
+)
+
+// PolicyError is returned for a password that breaks the policy. Params are the
+// limits of the rule, for the message.
+type PolicyError struct {
+	Violation Violation
+	Params    []string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("password violates policy: %s", e.Violation)
+}
+
+// Policy is what new passwords have to satisfy. Lengths are counted in
+// characters, except MaxLength, which is counted in bytes like bcrypt does.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// CharacterClasses is how many of lowercase letters, uppercase letters,
+	// digits and symbols the password has to contain
+	CharacterClasses int
+	// MinStrength is the lowest Strength score accepted
+	MinStrength int
+	// Breached are passwords known from data breaches, nil to skip the check
+	Breached BreachedList
+}
+
+func getIntEnv(key string, def int) int {
+	value, err := strconv.Atoi(config.GetEnvDefault(key, strconv.Itoa(def)))
+	if err != nil || value < 0 {
+		return def
+	}
+	return value
+}
+
+// GetPolicy returns the configured password policy
+func GetPolicy() Policy {
+	return Policy{
+		MinLength:        getIntEnv("PASSWORD_MIN_LENGTH", DefaultMinLength),
+		MaxLength:        getIntEnv("PASSWORD_MAX_LENGTH", DefaultMaxLength),
+		CharacterClasses: getIntEnv("PASSWORD_CHARACTER_CLASSES", DefaultCharacterClasses),
+		MinStrength:      getIntEnv("PASSWORD_MIN_STRENGTH", DefaultMinStrength),
+		Breached:         breachedList,
+	}
+}
+
+// GetHistorySize returns how many recent passwords of a user, the current one
+// included, can't be set again. 0 turns the check off.
+func GetHistorySize() int {
+	return getIntEnv("PASSWORD_HISTORY_SIZE", DefaultHistorySize)
+}
+
+// Check returns a *PolicyError for the first rule the password breaks. Personal
+// are the email address and names of the user, which the password must not
+// contain and which make it easier to guess.
+func (p Policy) Check(password string, personal ...string) error {
+	if length := utf8.RuneCountInString(password); length < p.MinLength {
+		return &PolicyError{Violation: ViolationTooShort, Params: []string{strconv.Itoa(p.MinLength)}}
+	}
+
+	if len(password) > p.MaxLength {
+		return &PolicyError{Violation: ViolationTooLong, Params: []string{strconv.Itoa(p.MaxLength)}}
+	}
+
+	if characterClasses(password).count() < p.CharacterClasses {
+		return &PolicyError{Violation: ViolationCharacterClasses, Params: []string{strconv.Itoa(p.CharacterClasses)}}
+	}
+
+	personalWords := personalWords(personal)
+	lower := strings.ToLower(password)
+	for _, word := range personalWords {
+		if strings.Contains(lower, word) {
+			return &PolicyError{Violation: ViolationPersonalInfo}
+		}
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return fmt.Errorf("checking breached passwords: %w", err)
+		}
+		if breached {
+			return &PolicyError{Violation: ViolationBreached}
+		}
+	}
+
+	if Strength(password, personalWords...) < p.MinStrength {
+		return &PolicyError{Violation: ViolationTooWeak}
+	}
+
+	return nil
+}
+
+// personalWords splits email addresses into the whole address and its local
+// part, leaving out words too short to matter
+func personalWords(personal []string) []string {
+	var words []string
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		candidates := []string{value}
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			candidates = append(candidates, local)
+		}
+
+		for _, word := range candidates {
+			if utf8.RuneCountInString(word) >= minWordLength {
+				words = append(words, word)
+			}
+		}
+	}
+	return words
+}
+
+// ValidateConfig reports every problem with the configured password policy
+func ValidateConfig() error {
+	var errs []error
+
+	keys := []string{"PASSWORD_MIN_LENGTH", "PASSWORD_MAX_LENGTH", "PASSWORD_CHARACTER_CLASSES", "PASSWORD_MIN_STRENGTH", "PASSWORD_HISTORY_SIZE"}
+	for _, key := range keys {
+		value := config.GetEnvDefault(key, "")
+		if value == "" {
+			continue
+		}
+		if parsed, err := strconv.Atoi(value); err != nil || parsed < 0 {
+			errs = append(errs, fmt.Errorf("%s must be a number", key))
+		}
+	}
+
+	policy := GetPolicy()
+	if policy.MinLength < 1 {
+		errs = append(errs, errors.New("PASSWORD_MIN_LENGTH must be at least 1"))
+	}
+	if policy.MaxLength < policy.MinLength {
+		errs = append(errs, errors.New("PASSWORD_MAX_LENGTH must not be shorter than PASSWORD_MIN_LENGTH"))
+	}
+	if policy.CharacterClasses > 4 {
+		errs = append(errs, errors.New("PASSWORD_CHARACTER_CLASSES must be between 0 and 4"))
+	}
+	if policy.MinStrength > 4 {
+		errs = append(errs, errors.New("PASSWORD_MIN_STRENGTH must be between 0 and 4"))
+	}
+
+	if err := ValidateBreachedConfig(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
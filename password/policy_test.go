@@ -0,0 +1,85 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy := Policy{
+		MinLength:        8,
+		MaxLength:        BcryptMaxLength,
+		CharacterClasses: 2,
+		MinStrength:      2,
+	}
+
+	tests := []struct {
+		name      string
+		password  string
+		violation Violation
+	}{
+		{name: "ok", password: "zebra-lamp-42"},
+		{name: "too-short", password: "ab1!", violation: ViolationTooShort},
+		{name: "too-long", password: "Aa1-" + string(make([]byte, 70)), violation: ViolationTooLong},
+		{name: "multibyte-too-long", password: "ž1žžžžžžžžžžžžžžžžžžžžžžžžžžžžžžžžžžžž", violation: ViolationTooLong},
+		{name: "character-classes", password: "onlylowercaseletters", violation: ViolationCharacterClasses},
+		{name: "contains-email", password: "Customer@example.com1", violation: ViolationPersonalInfo},
+		{name: "contains-email-local-part", password: "mycustomer-2024", violation: ViolationPersonalInfo},
+		{name: "contains-name", password: "jane-doe-1990!", violation: ViolationPersonalInfo},
+		{name: "common-password", password: "password1", violation: ViolationTooWeak},
+		{name: "keyboard-walk", password: "qwertyuiop123", violation: ViolationTooWeak},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := policy.Check(testCase.password, "customer@example.com", "Jane", "Doe-Smith", "jane-doe")
+
+			if testCase.violation == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var policyErr *PolicyError
+			require.ErrorAs(t, err, &policyErr)
+			assert.Equal(t, testCase.violation, policyErr.Violation)
+		})
+	}
+}
+
+func TestStrength(t *testing.T) {
+	tests := []struct {
+		password string
+		score    int
+	}{
+		{password: "12345678", score: 0},
+		{password: "p@ssw0rd", score: 0},
+		{password: "aaaaaaaaaa", score: 0},
+		{password: "abcdefgh1", score: 1},
+		{password: "password123", score: 1},
+		{password: "zebra-lamp-42", score: 4},
+		{password: "correct horse battery staple", score: 4},
+	}
+
+	for _, testCase := range tests {
+		assert.Equal(t, testCase.score, Strength(testCase.password), testCase.password)
+	}
+
+	// Personal words are as easy to guess as common passwords
+	assert.Less(t, Strength("martinkrpan", "martinkrpan"), Strength("martinkrpan"))
+}
+
+func TestValidateConfig(t *testing.T) {
+	assert.NoError(t, ValidateConfig())
+
+	t.Setenv("PASSWORD_MIN_LENGTH", "12")
+	t.Setenv("PASSWORD_MAX_LENGTH", "10")
+	t.Setenv("PASSWORD_MIN_STRENGTH", "5")
+	t.Setenv("PASSWORD_CHARACTER_CLASSES", "many")
+
+	err := ValidateConfig()
+	assert.ErrorContains(t, err, "PASSWORD_MAX_LENGTH")
+	assert.ErrorContains(t, err, "PASSWORD_MIN_STRENGTH")
+	assert.ErrorContains(t, err, "PASSWORD_CHARACTER_CLASSES")
+}
@@ -0,0 +1,228 @@
+package password
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords are the most used passwords, most common first
+//
+//go:embed common.txt
+var commonPasswordsFile string
+
+var commonPasswords = func() map[string]int {
+	ranks := map[string]int{}
+	for i, word := range strings.Fields(commonPasswordsFile) {
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}()
+
+// keyboardRows are sequences people walk along on a keyboard
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"abcdefghijklmnopqrstuvwxyz",
+	"01234567890",
+}
+
+// leetReplacements undoes the usual substitutions of letters by digits and symbols
+var leetReplacements = strings.NewReplacer(
+	"@", "a", "4", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t",
+)
+
+const (
+	// minPatternLength is the shortest repeat or sequence counted as a pattern
+	minPatternLength = 3
+	// minWordLength is the shortest common password or personal word looked for
+	// inside a password
+	minWordLength = 4
+)
+
+// Strength estimates how hard the password is to guess, in the spirit of zxcvbn.
+// The password is split into common passwords, personal words, keyboard and
+// alphabetical sequences, repeats and random characters, and the guesses needed
+// for every part are multiplied. The score is 0 (too guessable) to 4 (very
+// unguessable), with the same thresholds as zxcvbn.
+func Strength(password string, personal ...string) int {
+	guesses := estimateGuesses(password, personal)
+
+	switch log := math.Log10(guesses); {
+	case log < 3:
+		return 0
+	case log < 6:
+		return 1
+	case log < 8:
+		return 2
+	case log < 10:
+		return 3
+	}
+	return 4
+}
+
+func estimateGuesses(password string, personal []string) float64 {
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	normalized := []rune(leetReplacements.Replace(strings.ToLower(password)))
+	if len(normalized) != len(lower) {
+		normalized = lower
+	}
+
+	personalWords := map[string]bool{}
+	for _, word := range personal {
+		word = strings.ToLower(word)
+		if len([]rune(word)) >= minWordLength {
+			personalWords[word] = true
+		}
+	}
+
+	pool := float64(characterPool(password))
+	guesses := 1.0
+
+	for i := 0; i < len(runes); {
+		length, patternGuesses := bestPattern(lower, normalized, i, personalWords)
+		if length == 0 {
+			guesses *= pool
+			i++
+			continue
+		}
+
+		// Capitalizing or substituting characters in a pattern doesn't help much
+		if string(runes[i:i+length]) != string(lower[i:i+length]) {
+			patternGuesses *= 2
+		}
+		if string(lower[i:i+length]) != string(normalized[i:i+length]) {
+			patternGuesses *= 2
+		}
+
+		guesses *= patternGuesses
+		i += length
+	}
+
+	return guesses
+}
+
+// bestPattern returns the longest pattern starting at the position, and the
+// guesses needed for it
+func bestPattern(lower []rune, normalized []rune, start int, personalWords map[string]bool) (int, float64) {
+	bestLength, bestGuesses := 0, 0.0
+	consider := func(length int, guesses float64) {
+		if length > bestLength || (length == bestLength && guesses < bestGuesses) {
+			bestLength, bestGuesses = length, guesses
+		}
+	}
+
+	for end := len(lower); end-start >= minWordLength; end-- {
+		for _, candidate := range []string{string(lower[start:end]), string(normalized[start:end])} {
+			if rank, ok := commonPasswords[candidate]; ok {
+				consider(end-start, float64(rank))
+			}
+			if personalWords[candidate] {
+				consider(end-start, 1)
+			}
+		}
+	}
+
+	if length := repeatLength(lower, start); length >= minPatternLength {
+		consider(length, float64(10*length))
+	}
+	if length := sequenceLength(lower, start); length >= minPatternLength {
+		consider(length, float64(20*length))
+	}
+
+	return bestLength, bestGuesses
+}
+
+// repeatLength returns how often the character at the position is repeated
+func repeatLength(lower []rune, start int) int {
+	end := start + 1
+	for end < len(lower) && lower[end] == lower[start] {
+		end++
+	}
+	return end - start
+}
+
+// sequenceLength returns the length of the keyboard or alphabetical sequence, in
+// either direction, starting at the position
+func sequenceLength(lower []rune, start int) int {
+	longest := 0
+	for _, row := range keyboardRows {
+		rowRunes := []rune(row)
+		for _, direction := range []int{1, -1} {
+			length := 0
+			position := indexRune(rowRunes, lower[start])
+			for position >= 0 && position < len(rowRunes) && start+length < len(lower) && rowRunes[position] == lower[start+length] {
+				length++
+				position += direction
+			}
+			longest = max(longest, length)
+		}
+	}
+	return longest
+}
+
+func indexRune(runes []rune, r rune) int {
+	for i, candidate := range runes {
+		if candidate == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// characterPool returns the number of characters a brute force attack on the
+// password has to try for each position
+func characterPool(password string) int {
+	pool := 0
+	classes := characterClasses(password)
+	if classes.lower {
+		pool += 26
+	}
+	if classes.upper {
+		pool += 26
+	}
+	if classes.digit {
+		pool += 10
+	}
+	if classes.symbol {
+		pool += 33
+	}
+	return max(pool, 10)
+}
+
+type classes struct {
+	lower, upper, digit, symbol bool
+}
+
+func (c classes) count() int {
+	count := 0
+	for _, present := range []bool{c.lower, c.upper, c.digit, c.symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+func characterClasses(password string) classes {
+	var c classes
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			c.lower = true
+		case unicode.IsUpper(r):
+			c.upper = true
+		case unicode.IsDigit(r):
+			c.digit = true
+		default:
+			c.symbol = true
+		}
+	}
+	return c
+}
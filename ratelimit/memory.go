@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// memoryCleanupInterval is how often buckets that are full again are dropped
+const memoryCleanupInterval = time.Minute
+
+type memoryBucket struct {
+	Bucket
+	fullAt time.Time
+}
+
+// MemoryStore keeps the buckets in process, so they are not shared between replicas
+type MemoryStore struct {
+	mu          sync.Mutex
+	buckets     map[string]*memoryBucket
+	lastCleanup time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*memoryBucket{},
+	}
+}
+
+func (s *MemoryStore) Take(key string, limit Limit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastCleanup) >= memoryCleanupInterval {
+		s.cleanup(now)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{Bucket: NewBucket(limit, now)}
+		s.buckets[key] = bucket
+	}
+
+	allowed, retryAfter := bucket.Take(limit, now)
+	bucket.fullAt = bucket.FullAt(limit)
+	return allowed, retryAfter, nil
+}
+
+// cleanup drops the buckets that are full again, they behave the same as new ones
+func (s *MemoryStore) cleanup(now time.Time) {
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastCleanup = now
+}
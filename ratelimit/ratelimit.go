@@ -0,0 +1,140 @@
+// Package ratelimit implements token bucket rate limiting with pluggable storage
+// for the buckets, so limits can be shared by all replicas of the service.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PRPO-skupina-02/common/config"
+)
+
+const (
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+)
+
+// Limit allows bursts of up to Requests requests, refilled evenly over Per
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit parses a limit written as requests/period, such as 20/1m
+func ParseLimit(value string) (Limit, error) {
+	requests, per, ok := strings.Cut(value, "/")
+	if !ok {
+		return Limit{}, errors.New("must be requests/period such as 20/1m")
+	}
+
+	limit := Limit{}
+	var err error
+	limit.Requests, err = strconv.Atoi(requests)
+	if err != nil || limit.Requests <= 0 {
+		return Limit{}, errors.New("must allow a positive number of requests")
+	}
+	limit.Per, err = time.ParseDuration(per)
+	if err != nil || limit.Per <= 0 {
+		return Limit{}, errors.New("must have a positive period such as 1m or 1h")
+	}
+	return limit, nil
+}
+
+// GetLimitEnv returns the limit configured in the env var, or def. Invalid values
+// fall back to the default, they are reported by ValidateConfig.
+func GetLimitEnv(key string, def Limit) Limit {
+	value := config.GetEnvDefault(key, "")
+	if value == "" {
+		return def
+	}
+	limit, err := ParseLimit(value)
+	if err != nil {
+		return def
+	}
+	return limit
+}
+
+// rate returns how many tokens are added to the bucket per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Bucket holds the tokens left for one key. A request takes one token, and
+// tokens are added back at the rate of the limit until the bucket is full.
+type Bucket struct {
+	Tokens     float64
+	RefilledAt time.Time
+}
+
+// NewBucket returns a full bucket
+func NewBucket(limit Limit, now time.Time) Bucket {
+	return Bucket{Tokens: float64(limit.Requests), RefilledAt: now}
+}
+
+// Take adds the tokens refilled since the bucket was last used and takes one. If
+// the bucket is empty, no token is taken and Take returns how long until there
+// is one.
+func (b *Bucket) Take(limit Limit, now time.Time) (bool, time.Duration) {
+	elapsed := now.Sub(b.RefilledAt).Seconds()
+	if elapsed > 0 {
+		b.Tokens = math.Min(float64(limit.Requests), b.Tokens+elapsed*limit.rate())
+		b.RefilledAt = now
+	}
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.Tokens) / limit.rate()
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// FullAt returns when the bucket is full again if it isn't used, after which it
+// no longer has to be stored
+func (b Bucket) FullAt(limit Limit) time.Time {
+	missing := float64(limit.Requests) - b.Tokens
+	return b.RefilledAt.Add(time.Duration(missing / limit.rate() * float64(time.Second)))
+}
+
+// Store keeps the buckets of all keys
+type Store interface {
+	// Take takes a token from the bucket with the given key, starting with a full
+	// bucket for a new key. If the bucket is empty, it returns false and how long
+	// until a token is available.
+	Take(key string, limit Limit) (bool, time.Duration, error)
+}
+
+// GetBackend returns where buckets are stored. Memory is enough for a single
+// replica, more replicas have to share the buckets in Postgres.
+func GetBackend() string {
+	return config.GetEnvDefault("RATE_LIMIT_BACKEND", BackendMemory)
+}
+
+// ValidateConfig reports every problem with the configured rate limiting backend
+// and limits. Every other RATE_LIMIT_ env var is a limit.
+func ValidateConfig() error {
+	var errs []error
+
+	backend := GetBackend()
+	if backend != BackendMemory && backend != BackendPostgres {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_BACKEND must be %s or %s", BackendMemory, BackendPostgres))
+	}
+
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(key, "RATE_LIMIT_") || key == "RATE_LIMIT_BACKEND" || value == "" {
+			continue
+		}
+		if _, err := ParseLimit(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s %w", key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketTake(t *testing.T) {
+	limit := Limit{Requests: 2, Per: time.Minute}
+	now := time.Now()
+	bucket := NewBucket(limit, now)
+
+	allowed, _ := bucket.Take(limit, now)
+	assert.True(t, allowed)
+	allowed, _ = bucket.Take(limit, now)
+	assert.True(t, allowed)
+
+	allowed, retryAfter := bucket.Take(limit, now)
+	assert.False(t, allowed)
+	assert.InDelta(t, 30*time.Second, retryAfter, float64(time.Millisecond))
+
+	// One token is refilled every 30 seconds
+	allowed, retryAfter = bucket.Take(limit, now.Add(20*time.Second))
+	assert.False(t, allowed)
+	assert.InDelta(t, 10*time.Second, retryAfter, float64(time.Millisecond))
+
+	allowed, _ = bucket.Take(limit, now.Add(30*time.Second))
+	assert.True(t, allowed)
+
+	assert.Equal(t, now.Add(90*time.Second), bucket.FullAt(limit))
+
+	// The bucket never holds more than the burst
+	later := now.Add(time.Hour)
+	for range 2 {
+		allowed, _ = bucket.Take(limit, later)
+		assert.True(t, allowed)
+	}
+	allowed, _ = bucket.Take(limit, later)
+	assert.False(t, allowed)
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 1, Per: time.Hour}
+
+	allowed, _, err := store.Take("login:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, retryAfter, err := store.Take("login:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Greater(t, retryAfter, 59*time.Minute)
+
+	// Keys have separate buckets
+	allowed, _, err = store.Take("login:192.0.2.2", limit)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		limit Limit
+		valid bool
+	}{
+		{
+			name:  "ok",
+			value: "20/1m",
+			limit: Limit{Requests: 20, Per: time.Minute},
+			valid: true,
+		},
+		{
+			name:  "missing-period",
+			value: "20",
+		},
+		{
+			name:  "zero-requests",
+			value: "0/1m",
+		},
+		{
+			name:  "invalid-period",
+			value: "20/minute",
+		},
+		{
+			name:  "negative-period",
+			value: "20/-1m",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			limit, err := ParseLimit(testCase.value)
+			if testCase.valid {
+				require.NoError(t, err)
+				assert.Equal(t, testCase.limit, limit)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestGetLimitEnv(t *testing.T) {
+	def := Limit{Requests: 10, Per: time.Minute}
+
+	assert.Equal(t, def, GetLimitEnv("RATE_LIMIT_LOGIN_IP", def))
+
+	t.Setenv("RATE_LIMIT_LOGIN_IP", "5/1h")
+	assert.Equal(t, Limit{Requests: 5, Per: time.Hour}, GetLimitEnv("RATE_LIMIT_LOGIN_IP", def))
+
+	t.Setenv("RATE_LIMIT_LOGIN_IP", "invalid")
+	assert.Equal(t, def, GetLimitEnv("RATE_LIMIT_LOGIN_IP", def))
+}
+
+func TestValidateConfig(t *testing.T) {
+	assert.NoError(t, ValidateConfig())
+
+	t.Setenv("RATE_LIMIT_BACKEND", "redis")
+	t.Setenv("RATE_LIMIT_LOGIN_IP", "many")
+	err := ValidateConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "RATE_LIMIT_BACKEND")
+	assert.Contains(t, err.Error(), "RATE_LIMIT_LOGIN_IP")
+}